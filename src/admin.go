@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// reloadMu serializes reloads, which replace envFile and the live settings.
+var reloadMu sync.Mutex
+
+// requireAdmin checks the bearer token of an /admin request and replies
+// with an error when it is missing or wrong. Without -admin-token the admin
+// endpoints don't exist.
+func requireAdmin(cfg *Config, w http.ResponseWriter, r *http.Request) bool {
+	token := cfg.Live().AdminToken
+	if token == "" {
+		writeError(w, http.StatusNotFound, codeNotFound, "Not Found")
+		return false
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// adminReloadHandler handles POST /admin/reload. It reads the configuration
+// again and applies the Reloadable settings without restarting, so open
+// connections are kept. The response lists the settings that changed and
+// were applied, and those that changed but need a restart, such as the
+// listen ports. An invalid configuration is rejected as a whole and the
+// running one is kept.
+func adminReloadHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		if !requireAdmin(cfg, w, r) {
+			return
+		}
+
+		reloaded, restartRequired, err := reloadConfig(cfg)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeInvalidConfig, "Invalid configuration, keeping the current one: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"reloaded":        reloaded,
+			"restartRequired": restartRequired,
+		})
+	}
+}
+
+// reloadConfig parses the command line again, with the current environment
+// and ENV_FILE, and swaps the new Reloadable settings into cfg. It returns
+// the names of the reloaded settings that changed and of the changed
+// settings that only apply after a restart.
+func reloadConfig(cfg *Config) (reloaded, restartRequired []string, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	next, err := loadConfig(fs, os.Args[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	live := next.Live()
+	restartRequired = []string{}
+	// The quota middleware is only installed when the server starts with a
+	// quota, so turning it on takes a restart.
+	if !cfg.quotaEnabled && live.DailyQuota > 0 {
+		live.DailyQuota = 0
+		restartRequired = append(restartRequired, "DailyQuota")
+	}
+	reloaded = changedFields(cfg.Live(), live)
+	restartRequired = append(restartRequired, changedFields(cfg, next)...)
+	cfg.live.Store(live)
+
+	if len(reloaded) > 0 {
+		log.Printf("Reloaded configuration, changed: %s", strings.Join(reloaded, ", "))
+	} else {
+		log.Printf("Reloaded configuration, nothing changed")
+	}
+	if len(restartRequired) > 0 {
+		log.Printf("Configuration changes that require a restart: %s", strings.Join(restartRequired, ", "))
+	}
+	return reloaded, restartRequired, nil
+}
+
+// changedFields returns the names of the exported fields that differ
+// between the structs old and new point to. Patterns are compared by their
+// source, since every parse compiles a new one.
+func changedFields(old, new interface{}) []string {
+	a, b := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	changed := []string{}
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		x, y := a.Field(i).Interface(), b.Field(i).Interface()
+		if _, ok := x.(*regexp.Regexp); ok {
+			x, y = fmt.Sprint(x), fmt.Sprint(y)
+		}
+		if !reflect.DeepEqual(x, y) {
+			changed = append(changed, field.Name)
+		}
+	}
+	return changed
+}
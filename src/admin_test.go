@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// reloadRequest sends POST /admin/reload with the bearer token.
+func reloadRequest(api http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminReload(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "shopping.env")
+	writeEnv := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(envPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEnv("ADMIN_TOKEN=secret\n")
+	t.Setenv("ENV_FILE", envPath)
+
+	// reloadConfig parses the command line again, so the server runs with
+	// one of its own.
+	args := []string{"-fsync=false"}
+	for _, name := range []string{"data", "purchases", "changes", "sections", "preferences", "quota"} {
+		args = append(args, "-"+name+"-file", filepath.Join(dir, name+".json"))
+	}
+	testArgs := os.Args
+	os.Args = append([]string{"shopping"}, args...)
+	defer func() { os.Args = testArgs }()
+	fs := flag.NewFlagSet("shopping", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(cfg)
+	api := NewRouter(cfg, s)
+
+	if rec := reloadRequest(api, "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reload with a wrong token: status %d, want 401", rec.Code)
+	}
+
+	writeEnv("ADMIN_TOKEN=secret\nDATA_CACHE_CONTROL=max-age=60\nMIRROR_FILE=" + filepath.Join(dir, "mirror.json") + "\n")
+	rec := reloadRequest(api, "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reload: status %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+	var result struct {
+		Reloaded        []string `json:"reloaded"`
+		RestartRequired []string `json:"restartRequired"`
+	}
+	decodeBody(t, rec, &result)
+	if !slices.Equal(result.Reloaded, []string{"DataCacheControl"}) || !slices.Equal(result.RestartRequired, []string{"MirrorFile"}) {
+		t.Errorf("reload result = %+v, want DataCacheControl reloaded and MirrorFile needing a restart", result)
+	}
+	if got := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK).Header().Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("Cache-Control after reload = %q, want max-age=60", got)
+	}
+
+	// An invalid configuration is rejected and the running one kept.
+	writeEnv("ADMIN_TOKEN=other\nBLANK_NAMES=sometimes\n")
+	if rec := reloadRequest(api, "secret"); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("invalid reload: status %d, want 422; body: %s", rec.Code, rec.Body)
+	}
+	if got := s.cfg.Live().AdminToken; got != "secret" {
+		t.Errorf("admin token = %q after a rejected reload, want secret", got)
+	}
+}
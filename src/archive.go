@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// isArchived reports whether an item has been archived. Archived items stay
+// in the catalog, so their image and tags are kept for next season, but are
+// left out of item listings by default.
+func isArchived(item JSONData) bool {
+	archived, _ := item["archived"].(bool)
+	return archived
+}
+
+// activeItems returns the catalog items that are not archived.
+func activeItems(data JSONData) []JSONData {
+	items := catalogItems(data)
+	active := make([]JSONData, 0, len(items))
+	for _, item := range items {
+		if !isArchived(item) {
+			active = append(active, item)
+		}
+	}
+	return active
+}
+
+// archiveItemHandler handles POST /data/items/{id}/archive and
+// POST /data/items/{id}/unarchive. Archiving also takes the item off the
+// pending list. The updated item is returned.
+func archiveItemHandler(s *Store, archived bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		id := mux.Vars(r)["id"]
+		var updated JSONData
+		err := s.update(r.Context(), func(data JSONData) error {
+			item, err := findItem(data, id)
+			if err != nil {
+				return err
+			}
+			if archived {
+				item["archived"] = true
+				replacePendingRefs(data, id, nil)
+			} else {
+				delete(item, "archived")
+			}
+			updated = item
+			return nil
+		})
+		writeItemResult(w, r, updated, err)
+	}
+}
+
+// listArchivedHandler handles GET /data/archived, returning archived items.
+func listArchivedHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /data/archived: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		archived := []JSONData{}
+		for _, item := range catalogItems(data) {
+			if isArchived(item) {
+				archived = append(archived, item)
+			}
+		}
+		writeJSONWithETag(s.cfg, w, r, archived)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// autocompleteMatch reports whether a folded item name starts with the
+// folded prefix, or has a word that does, so "av" finds "Leche de avena".
+func autocompleteMatch(name, prefix string) bool {
+	if strings.HasPrefix(name, prefix) {
+		return true
+	}
+	for _, word := range strings.Fields(name) {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// autocompleteHandler handles GET /autocomplete?q=mi&limit=10, returning the
+// names of catalog items, archived ones included, that start with q or have
+// a word that does, ignoring case and accents ("lim" finds "Limón"). Names
+// bought more often come first, then those bought more recently, then
+// alphabetically. limit defaults to, and may not exceed,
+// -autocomplete-limit. An empty q, or no match, returns an empty array.
+func autocompleteHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		limit := s.cfg.AutocompleteLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 || parsed > s.cfg.AutocompleteLimit {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Parameter limit must be between 1 and "+strconv.Itoa(s.cfg.AutocompleteLimit))
+				return
+			}
+			limit = parsed
+		}
+		prefix := foldAccents(strings.Join(strings.Fields(r.URL.Query().Get("q")), " "))
+		if prefix == "" {
+			writeJSON(w, http.StatusOK, []string{})
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /autocomplete: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		type candidate struct {
+			name          string
+			count         int
+			lastPurchased time.Time
+		}
+		records := s.purchases.snapshot()
+		byName := map[string]*candidate{}
+		var candidates []*candidate
+		for _, item := range catalogItems(data) {
+			name, _ := item["name"].(string)
+			name = strings.Join(strings.Fields(name), " ")
+			key := foldAccents(name)
+			if key == "" || !autocompleteMatch(key, prefix) {
+				continue
+			}
+			c, seen := byName[key]
+			if !seen {
+				c = &candidate{name: name}
+				byName[key] = c
+				candidates = append(candidates, c)
+			}
+			id, _ := item["id"].(string)
+			if rec, ok := records[id]; ok {
+				c.count += rec.Count
+				if rec.LastPurchased.After(c.lastPurchased) {
+					c.lastPurchased = rec.LastPurchased
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].count != candidates[j].count {
+				return candidates[i].count > candidates[j].count
+			}
+			if !candidates[i].lastPurchased.Equal(candidates[j].lastPurchased) {
+				return candidates[i].lastPurchased.After(candidates[j].lastPurchased)
+			}
+			return foldAccents(candidates[i].name) < foldAccents(candidates[j].name)
+		})
+
+		names := []string{}
+		for _, c := range candidates {
+			if len(names) == limit {
+				break
+			}
+			names = append(names, c.name)
+		}
+		writeJSON(w, http.StatusOK, names)
+	}
+}
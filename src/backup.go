@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+)
+
+// instanceBackup is the whole state of the instance: the document the web
+// client edits plus the state kept in files next to it.
+type instanceBackup struct {
+	Data        JSONData                  `json:"data"`
+	Purchases   map[string]purchaseRecord `json:"purchases"`
+	Sections    map[string]int            `json:"sections"`
+	Preferences map[string]string         `json:"preferences"`
+}
+
+// exportAllHandler handles GET /export/all, returning the document, the
+// purchase history, the store sections and the preferences in a single
+// backup document that POST /import/all restores.
+func exportAllHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /export/all: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		sections, err := s.loadSections()
+		if err != nil {
+			log.Printf("Error in GET /export/all: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		preferences, err := s.loadPreferences()
+		if err != nil {
+			log.Printf("Error in GET /export/all: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="shopping-list-backup.json"`)
+		writeJSON(w, http.StatusOK, instanceBackup{Data: data, Purchases: s.purchases.snapshot(), Sections: sections, Preferences: preferences})
+	}
+}
+
+// importAllHandler handles POST /import/all with a backup produced by
+// GET /export/all. The body is decoded and the document normalized the
+// same way as for PUT /data (see parseDocument). The whole backup is
+// validated before anything is replaced; the document is then replaced
+// first, so a failure leaves the rest as it was. Backups made before
+// preferences were exported leave the current preferences alone.
+//
+// With ?preview=true nothing is replaced; the response has the counts and
+// the ids the restore would add, remove or overwrite in the document.
+func importAllHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Could not read request body")
+			return
+		}
+		if s.cfg.DecodeUploads {
+			if body, err = decodeUpload(body); err != nil {
+				writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+				return
+			}
+		}
+
+		// The document is kept raw to go through parseDocument on its own.
+		var raw struct {
+			Data        json.RawMessage           `json:"data"`
+			Purchases   map[string]purchaseRecord `json:"purchases"`
+			Sections    map[string]int            `json:"sections"`
+			Preferences map[string]interface{}    `json:"preferences"`
+		}
+		if err := decodeJSON(s.cfg, bytes.NewReader(body), &raw); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+		if len(raw.Data) == 0 || string(raw.Data) == "null" {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, "Backup has no data document")
+			return
+		}
+		data, err := parseDocument(s.cfg, raw.Data)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		backup := instanceBackup{Data: data, Purchases: raw.Purchases, Sections: raw.Sections}
+		if raw.Preferences != nil {
+			if backup.Preferences, err = parsePreferences(s.cfg, raw.Preferences); err != nil {
+				writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, "preferences: "+err.Error())
+				return
+			}
+		}
+
+		dropBlankNames(s.cfg, backup.Data)
+		if err := validateDocument(s.cfg, backup.Data); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, "data."+err.Error())
+			return
+		}
+		if backup.Purchases == nil {
+			backup.Purchases = map[string]purchaseRecord{}
+		}
+		if backup.Sections == nil {
+			backup.Sections = map[string]int{}
+		}
+
+		if r.URL.Query().Get("preview") == "true" {
+			current, err := s.readDataFile(r.Context())
+			if err != nil {
+				log.Printf("Error in POST /import/all: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"preview":     true,
+				"items":       len(catalogItems(backup.Data)),
+				"purchases":   len(backup.Purchases),
+				"sections":    len(backup.Sections),
+				"preferences": len(backup.Preferences),
+				"diff":        diffDocuments(current, backup.Data),
+			})
+			return
+		}
+
+		_, err = s.replace(r.Context(), backup.Data)
+		if err == nil {
+			if err = s.purchases.replaceAll(backup.Purchases); err == nil {
+				err = s.sections.save(backup.Sections)
+			}
+		}
+		if err == nil && backup.Preferences != nil {
+			err = s.preferences.save(backup.Preferences)
+		}
+		writeItemResult(w, r, map[string]interface{}{
+			"items":       len(catalogItems(backup.Data)),
+			"purchases":   len(backup.Purchases),
+			"sections":    len(backup.Sections),
+			"preferences": len(backup.Preferences),
+		}, err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestImportAllRoundTrip(t *testing.T) {
+	_, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPut, "/settings", `{"theme": "dark", "units": "metric"}`, http.StatusOK)
+	backup := mustDo(t, api, http.MethodGet, "/export/all", "", http.StatusOK).Body.String()
+
+	// Restoring into a fresh instance brings back the preferences as well.
+	_, restored := newTestAPI(t)
+	mustDo(t, restored, http.MethodPost, "/import/all", backup, http.StatusOK)
+	var preferences map[string]string
+	decodeBody(t, mustDo(t, restored, http.MethodGet, "/settings", "", http.StatusOK), &preferences)
+	if preferences["theme"] != "dark" || preferences["units"] != "metric" {
+		t.Errorf("preferences = %v, want the exported ones", preferences)
+	}
+	if item := storedItem(t, restored, "milk"); item["name"] != "Milk" {
+		t.Errorf("restored item = %v", item)
+	}
+}
+
+func TestImportAllDecodesLikePutData(t *testing.T) {
+	_, api := newTestAPI(t, "-normalize-names")
+
+	// A byte order mark is stripped and names are normalized.
+	mustDo(t, api, http.MethodPost, "/import/all",
+		"\ufeff"+`{"data": {"catalog": [{"id": "milk", "name": "  Oat   milk "}], "pendingList": []}}`, http.StatusOK)
+	if item := storedItem(t, api, "milk"); item["name"] != "Oat milk" {
+		t.Errorf("name = %q, want it normalized", item["name"])
+	}
+
+	// Invalid preferences reject the whole backup, leaving the document.
+	mustDo(t, api, http.MethodPost, "/import/all",
+		`{"data": {"catalog": [], "pendingList": []}, "preferences": {"theme": "neon"}}`, http.StatusUnprocessableEntity)
+	storedItem(t, api, "milk")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// batch runs fn against a transaction store that holds the document in
+// memory, while the real store stays write-locked so no other write can
+// interleave. When fn returns true and the document changed, the result is
+// written to the data file (and the changes feed) in a single write;
+// otherwise everything is discarded. None of the batchable routes record
+// purchases, and the purchase history and store sections are not part of the
+// transaction.
+func (s *Store) batch(ctx context.Context, fn func(tx *Store) bool) error {
+	t := timingsFrom(ctx)
+	unlock, err := s.lockForWrite(t)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := s.timedRead(t)
+	if err != nil {
+		return err
+	}
+	tx := &Store{
+		filepath:  s.filepath,
+		cfg:       s.cfg,
+		mem:       copyDocument(data),
+		purchases: s.purchases,
+		changes:   s.changes,
+		sections:  s.sections,
+		defaults:  s.defaults,
+	}
+	if !fn(tx) || reflect.DeepEqual(data, tx.mem) {
+		return nil
+	}
+	if err := s.timedWrite(t, tx.mem); err != nil {
+		return err
+	}
+	s.recordChange(data, tx.mem)
+	return nil
+}
+
+// batchOperation is one request of a POST /batch.
+type batchOperation struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// batchRoutes lists what a POST /batch may run: the routes that change
+// catalog items and the pending list. The whole batch runs with the store
+// write-locked, so reads, the event stream, imports and the admin routes are
+// left out: a stream would hold the lock for as long as its client stays
+// connected, and an import waits on the network.
+var batchRoutes = func() *mux.Router {
+	router := mux.NewRouter()
+	allow := func(path string, methods ...string) {
+		router.Handle(path, http.NotFoundHandler()).Methods(methods...)
+	}
+	allow("/data/items", http.MethodPost, http.MethodDelete)
+	allow("/data/items/split", http.MethodPost)
+	allow("/data/items/combine", http.MethodPost)
+	allow("/data/items/{id}", http.MethodPatch)
+	allow("/data/items/{id}/archive", http.MethodPost)
+	allow("/data/items/{id}/unarchive", http.MethodPost)
+	allow("/data/items/{id}/tags", http.MethodPost)
+	allow("/data/items/{id}/tags/{tag}", http.MethodDelete)
+	allow("/data/categories/completed", http.MethodDelete)
+	allow("/data/categories/{name:.+}/complete", http.MethodPost, http.MethodDelete)
+	allow("/data/touch", http.MethodPost)
+	return router
+}()
+
+// batchAllowed reports whether op is one of batchRoutes.
+func batchAllowed(op batchOperation) bool {
+	req, err := http.NewRequest(strings.ToUpper(op.Method), op.Path, nil)
+	if err != nil {
+		return false
+	}
+	return batchRoutes.Match(req, &mux.RouteMatch{})
+}
+
+// batchHandler handles POST /batch with a body of the form
+// {"atomic": true, "operations": [{"method": "POST", "path": "/data/items",
+// "body": {...}}, ...]}. The operations run in order through the regular
+// routes, against the document as left by the previous operation, and the
+// outcome of each is reported like an RPC response. Nothing else can write
+// while the batch runs and its changes are saved in a single write. With
+// atomic set, a failed operation discards the whole batch: the remaining
+// operations are skipped and nothing is saved. Only the routes in
+// batchRoutes can be batched; anything else rejects the batch with 400
+// before it starts.
+func batchHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var body struct {
+			Atomic     bool             `json:"atomic"`
+			Operations []batchOperation `json:"operations"`
+		}
+		if err := decodeJSON(s.cfg, r.Body, &body); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+		for i, op := range body.Operations {
+			if op.Method == "" || !strings.HasPrefix(op.Path, "/") {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "Every operation needs a method and an absolute path")
+				return
+			}
+			if !batchAllowed(op) {
+				writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Operation %d (%s %s) can't be batched, only changes to items and the pending list can", i, strings.ToUpper(op.Method), op.Path))
+				return
+			}
+		}
+
+		results := make([]rpcResponse, 0, len(body.Operations))
+		committed := false
+		err := s.batch(r.Context(), func(tx *Store) bool {
+			api := NewRouter(s.cfg, tx)
+			for _, op := range body.Operations {
+				var payload interface{}
+				if len(op.Body) > 0 {
+					payload = op.Body
+				}
+				result := dispatch(api, r, strings.ToUpper(op.Method), op.Path, payload)
+				results = append(results, result)
+				if result.Error != nil && body.Atomic {
+					return false
+				}
+			}
+			committed = true
+			return true
+		})
+		writeItemResult(w, r, map[string]interface{}{
+			"committed": committed,
+			"results":   results,
+		}, err)
+	}
+}
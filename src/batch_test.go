@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchAppliesOperationsInOneWrite(t *testing.T) {
+	_, api := newTestAPI(t)
+
+	rec := mustDo(t, api, http.MethodPost, "/batch", `{"atomic": true, "operations": [
+		{"method": "POST", "path": "/data/items", "body": {"id": "milk", "name": "Milk"}},
+		{"method": "POST", "path": "/data/items/milk/tags", "body": {"tag": "dairy"}}
+	]}`, http.StatusOK)
+	var result struct {
+		Committed bool          `json:"committed"`
+		Results   []rpcResponse `json:"results"`
+	}
+	decodeBody(t, rec, &result)
+	if !result.Committed || len(result.Results) != 2 {
+		t.Fatalf("batch result = %+v, want both operations committed", result)
+	}
+
+	var items []JSONData
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data/items?tag=dairy", "", http.StatusOK), &items)
+	if len(items) != 1 || items[0]["id"] != "milk" {
+		t.Fatalf("items tagged dairy = %v, want milk", items)
+	}
+}
+
+func TestBatchLeavesPurchaseHistoryAlone(t *testing.T) {
+	s, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPost, "/batch", `{"operations": [
+		{"method": "POST", "path": "/data/items", "body": {"id": "milk", "name": "Milk"}}
+	]}`, http.StatusOK)
+	if _, err := os.Stat(s.cfg.PurchasesFile); !os.IsNotExist(err) {
+		t.Errorf("purchase history written by a batch: %v", err)
+	}
+}
+
+func TestBatchRejectsRoutesOutsideTheAllowlist(t *testing.T) {
+	for _, op := range []string{
+		`{"method": "GET", "path": "/data/events"}`,
+		`{"method": "GET", "path": "/data"}`,
+		`{"method": "PUT", "path": "/data", "body": {}}`,
+		`{"method": "POST", "path": "/import-url", "body": {"url": "http://example.com"}}`,
+		`{"method": "POST", "path": "/rpc", "body": {"method": "getData"}}`,
+		`{"method": "POST", "path": "/admin/reload"}`,
+		`{"method": "GET", "path": "/export/all"}`,
+		`{"method": "POST", "path": "/batch", "body": {"operations": []}}`,
+	} {
+		_, api := newTestAPI(t)
+		rec := mustDo(t, api, http.MethodPost, "/batch", `{"operations": [`+op+`]}`, http.StatusBadRequest)
+		if !strings.Contains(rec.Body.String(), codeBadRequest) {
+			t.Errorf("batch of %s: body %s, want %s", op, rec.Body, codeBadRequest)
+		}
+	}
+}
+
+// A stream inside a batch would hold the write lock until its client
+// disconnects. It must be turned away at once, leaving the store writable.
+func TestBatchRejectsStreamWithoutLocking(t *testing.T) {
+	_, api := newTestAPI(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/batch",
+		strings.NewReader(`{"operations": [{"method": "GET", "path": "/data/events"}]}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if ctx.Err() != nil {
+		t.Fatal("POST /batch with a stream blocked until its client gave up")
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d; body: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"name": "Milk"}`, http.StatusCreated)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pendingBoolFields are the boolean fields of pending list entries.
+var pendingBoolFields = []string{"checked"}
+
+// coerceBool converts the spellings of a boolean that clients commonly send
+// ("true", "1", 1, "yes", ...) to a real boolean.
+func coerceBool(v interface{}) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, true
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "yes", "on":
+			return true, true
+		case "false", "0", "no", "off", "":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// canonicalizeFields removes the listed fields that are null and converts
+// the boolean ones to real booleans. The first value that can't be read as
+// a boolean is reported and the remaining ones are still converted.
+func canonicalizeFields(obj map[string]interface{}, fields, boolFields []string) error {
+	for _, field := range fields {
+		if value, present := obj[field]; present && value == nil {
+			delete(obj, field)
+		}
+	}
+	var err error
+	for _, field := range boolFields {
+		value, present := obj[field]
+		if !present {
+			continue
+		}
+		if b, ok := coerceBool(value); ok {
+			obj[field] = b
+		} else if err == nil {
+			err = &validationError{msg: fmt.Sprintf("%s: %v is not a boolean", field, value)}
+		}
+	}
+	return err
+}
+
+// canonicalizeItem removes the null fields of a catalog item that have a
+// -field-types type and coerces its bool fields to booleans. Values that
+// can't be coerced are left in place and the first one is reported.
+func canonicalizeItem(cfg *Config, item JSONData) error {
+	var typed, bools []string
+	for field, typ := range cfg.FieldTypes {
+		typed = append(typed, field)
+		if typ == "bool" {
+			bools = append(bools, field)
+		}
+	}
+	sort.Strings(bools)
+	return canonicalizeFields(item, typed, bools)
+}
+
+// canonicalizeDocument runs when -canonicalize-values is on, before a
+// written document is validated: catalog items go through canonicalizeItem
+// and the checked field of pending entries is coerced to a boolean, naming
+// the entry when that's not possible.
+func canonicalizeDocument(cfg *Config, data JSONData) error {
+	// Items with values that can't be coerced are left for validateItem to
+	// reject, so lenient writes can skip them.
+	for _, item := range catalogItems(data) {
+		canonicalizeItem(cfg, item)
+	}
+	raw, _ := data[pendingKey].([]interface{})
+	for i, entry := range raw {
+		ref, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := canonicalizeFields(ref, pendingBoolFields, pendingBoolFields); err != nil {
+			return &validationError{msg: fmt.Sprintf("pendingList[%d].%s", i, err.Error())}
+		}
+	}
+	return nil
+}
+
+// canonicalizeItemBody canonicalizes the item in a request body and returns
+// it serialized again. A body that isn't a JSON object is returned as is for
+// the regular decoding to reject.
+func canonicalizeItemBody(cfg *Config, body []byte) ([]byte, error) {
+	if cfg.RejectDuplicateKeys {
+		if err := checkDuplicateKeys(body); err != nil {
+			return nil, err
+		}
+	}
+	var item JSONData
+	if err := json.Unmarshal(body, &item); err != nil || item == nil {
+		return body, nil
+	}
+	if err := canonicalizeItem(cfg, item); err != nil {
+		return nil, err
+	}
+	return json.Marshal(item)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// change is one entry of the changes feed: what a single write changed.
+type change struct {
+	Cursor int64        `json:"cursor"`
+	Time   time.Time    `json:"time"`
+	Diff   documentDiff `json:"diff"`
+}
+
+// ChangeLog is the replayable feed behind GET /data/changes. Every write that
+// changes the document appends an entry with the next cursor; only the most
+// recent entries are retained, but the cursor keeps counting so it never
+// repeats. The feed is kept in a file of its own, like the purchase history.
+type ChangeLog struct {
+	filepath string
+	max      int
+	mu       sync.RWMutex
+	cursor   int64
+	changes  []change
+}
+
+// changeLogFile is the on-disk form of the ChangeLog.
+type changeLogFile struct {
+	Cursor  int64    `json:"cursor"`
+	Changes []change `json:"changes"`
+}
+
+// NewChangeLog loads the changes feed from path, keeping at most max
+// entries. A missing file starts an empty feed, and so does one that can't be
+// parsed: the feed only saves clients a full reload, so it is reset with a
+// warning rather than keeping the server from starting.
+func NewChangeLog(path string, max int) (*ChangeLog, error) {
+	c := &ChangeLog{filepath: path, max: max}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading changes feed: %w", err)
+	}
+	if len(content) > 0 {
+		var file changeLogFile
+		if err := json.Unmarshal(content, &file); err != nil {
+			log.Printf("Changes feed %s is unreadable, starting an empty one: %v", path, err)
+			return c, nil
+		}
+		c.cursor, c.changes = file.Cursor, file.Changes
+	}
+	return c, nil
+}
+
+// record appends the difference between two versions of the document,
+// dropping the oldest entries beyond the retention limit, and returns the
+// new entry. Writes that changed nothing are not recorded and return nil. The
+// entry is returned even when saving the feed failed.
+func (c *ChangeLog) record(before, after JSONData) (*change, error) {
+	d := diffDocuments(before, after)
+	if len(d.Added)+len(d.Removed)+len(d.Changed) == 0 {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cursor++
+	ch := change{Cursor: c.cursor, Time: time.Now().UTC(), Diff: d}
+	c.changes = append(c.changes, ch)
+	if c.max > 0 && len(c.changes) > c.max {
+		c.changes = append([]change(nil), c.changes[len(c.changes)-c.max:]...)
+	}
+
+	content, err := json.MarshalIndent(changeLogFile{Cursor: c.cursor, Changes: c.changes}, "", "  ")
+	if err != nil {
+		return &ch, fmt.Errorf("error marshaling changes feed: %w", err)
+	}
+	if err := writeFileAtomic(c.filepath, content, 0644); err != nil {
+		return &ch, fmt.Errorf("error writing changes feed: %w", err)
+	}
+	return &ch, nil
+}
+
+// latest returns the cursor of the most recent change.
+func (c *ChangeLog) latest() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cursor
+}
+
+// since returns the retained changes after cursor, the latest cursor, and
+// whether the result is complete. It is incomplete when changes right after
+// cursor were already pruned, or cursor is ahead of the feed (e.g. the feed
+// file was reset); the client must then reload the whole document.
+func (c *ChangeLog) since(cursor int64) ([]change, int64, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	changes := []change{}
+	for _, ch := range c.changes {
+		if ch.Cursor > cursor {
+			changes = append(changes, ch)
+		}
+	}
+	complete := cursor <= c.cursor
+	if len(c.changes) > 0 && c.changes[0].Cursor > cursor+1 {
+		complete = false
+	}
+	return changes, c.cursor, complete
+}
+
+// changesHandler handles GET /data/changes?since=<cursor>, returning the
+// changes recorded after the cursor in order. Clients keep the returned
+// cursor and pass it as since on the next poll; since=0 (the default) asks
+// for every retained change. When complete is false some changes are no
+// longer retained and the client should reload GET /data instead.
+func changesHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var since int64
+		if v := r.URL.Query().Get("since"); v != "" {
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || parsed < 0 {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Parameter since must be a non-negative integer")
+				return
+			}
+			since = parsed
+		}
+
+		changes, cursor, complete := s.changes.since(since)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"cursor":   cursor,
+			"complete": complete,
+			"changes":  changes,
+		})
+	}
+}
+
+// recordChange appends a write to the changes feed and pushes its delta to
+// the subscribers of GET /data/events. The document is already saved, so a
+// failure here is only logged. Callers must hold s.mu, which keeps cursors
+// in the order the writes happened. Writes inside a batch are recorded as
+// one change when the batch commits.
+func (s *Store) recordChange(before, after JSONData) {
+	if s.mem != nil {
+		return
+	}
+	ch, err := s.changes.record(before, after)
+	if err != nil {
+		log.Printf("Error recording change: %v", err)
+	}
+	if ch != nil && s.events.active() {
+		s.events.publish(buildDelta(after, ch.Cursor, []change{*ch}))
+	}
+}
+
+// dataDelta is the response of GET /data?sinceVersion=N: the entries that
+// were added or updated since version N with their current value, and the
+// keys of the removed ones. Keys are the diff keys of the changes feed. When
+// the delta can't be computed, Full is set and Data holds the whole document.
+type dataDelta struct {
+	Version  int64                  `json:"version"`
+	Full     bool                   `json:"full"`
+	Upserted map[string]interface{} `json:"upserted,omitempty"`
+	Deleted  []string               `json:"deleted,omitempty"`
+	Data     JSONData               `json:"data,omitempty"`
+}
+
+// deltaSince reads the document and the changes after version together, so
+// the delta matches the returned version exactly.
+func (s *Store) deltaSince(ctx context.Context, version int64) (dataDelta, error) {
+	t := timingsFrom(ctx)
+	start := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t.since("lock", start)
+
+	data, err := s.timedRead(t)
+	if err != nil {
+		return dataDelta{}, err
+	}
+	changes, cursor, complete := s.changes.since(version)
+	if !complete {
+		return dataDelta{Version: cursor, Full: true, Data: data}, nil
+	}
+	return buildDelta(data, cursor, changes), nil
+}
+
+// buildDelta returns the delta that brings a copy of the document from
+// before changes to data, which is at version cursor. When the changes can't
+// be expressed per entry the delta is Full instead.
+func buildDelta(data JSONData, cursor int64, changes []change) dataDelta {
+	full := dataDelta{Version: cursor, Full: true, Data: data}
+	touched := map[string]bool{}
+	for _, ch := range changes {
+		for _, keys := range [][]string{ch.Diff.Added, ch.Diff.Changed, ch.Diff.Removed} {
+			for _, key := range keys {
+				// A keyed array diffed as a whole (some entry lacked
+				// an id) can't be expressed per entry.
+				if _, keyed := keyedArrays[key]; keyed {
+					return full
+				}
+				touched[key] = true
+			}
+		}
+	}
+
+	current := flattenDocument(data)
+	delta := dataDelta{Version: cursor, Upserted: map[string]interface{}{}, Deleted: []string{}}
+	for key := range touched {
+		if value, ok := current[key]; ok {
+			delta.Upserted[key] = value
+		} else {
+			delta.Deleted = append(delta.Deleted, key)
+		}
+	}
+	sort.Strings(delta.Deleted)
+	return delta
+}
+
+// readVersioned reads the document together with the changes feed cursor
+// it matches.
+func (s *Store) readVersioned(ctx context.Context) (JSONData, int64, error) {
+	t := timingsFrom(ctx)
+	start := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t.since("lock", start)
+
+	data, err := s.timedRead(t)
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, s.changes.latest(), nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestTornChangesFeedIsReset(t *testing.T) {
+	cfg := newTestConfig(t)
+	if err := os.WriteFile(cfg.ChangesFile, []byte(`{"cursor": 7, "changes": [{"cur`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore(cfg)
+	api := NewRouter(cfg, s)
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+	var feed struct {
+		Cursor  int64    `json:"cursor"`
+		Changes []change `json:"changes"`
+	}
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data/changes?since=0", "", http.StatusOK), &feed)
+	if feed.Cursor != 1 || len(feed.Changes) != 1 {
+		t.Errorf("feed = %+v, want one change after the reset", feed)
+	}
+
+	content, err := os.ReadFile(cfg.ChangesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &changeLogFile{}); err != nil {
+		t.Errorf("changes feed not rewritten: %v", err)
+	}
+}
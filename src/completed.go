@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// completedKey is the top-level field holding the categories marked as
+// done during the current shopping trip.
+const completedKey = "completedCategories"
+
+// completedCategories returns the set of completed category paths.
+func completedCategories(data JSONData) map[string]bool {
+	completed := map[string]bool{}
+	raw, _ := data[completedKey].([]interface{})
+	for _, v := range raw {
+		if category, ok := v.(string); ok {
+			completed[category] = true
+		}
+	}
+	return completed
+}
+
+// setCompletedCategories stores the completed categories sorted, removing
+// the field when there are none.
+func setCompletedCategories(data JSONData, completed map[string]bool) {
+	if len(completed) == 0 {
+		delete(data, completedKey)
+		return
+	}
+	categories := make([]string, 0, len(completed))
+	for category := range completed {
+		categories = append(categories, category)
+	}
+	slices.Sort(categories)
+	raw := make([]interface{}, len(categories))
+	for i, category := range categories {
+		raw[i] = category
+	}
+	data[completedKey] = raw
+}
+
+// categoryPath normalizes a category to the form the tree uses, so
+// " Food / Dairy" and "Food/Dairy" name the same category.
+func categoryPath(category string) string {
+	return strings.Join(categorySegments(category), "/")
+}
+
+// itemCategoryCompleted reports whether the category of an item is among
+// completed.
+func itemCategoryCompleted(item JSONData, completed map[string]bool) bool {
+	category, _ := item["category"].(string)
+	return completed[categoryPath(category)]
+}
+
+// resetCompletedCategories forgets the completed categories once the last
+// entry has left the pending list, which ends the shopping trip they were
+// marked in. Store.update and Store.replace call it on every write.
+func resetCompletedCategories(before, after JSONData) {
+	if _, ok := after[completedKey]; !ok {
+		return
+	}
+	if len(pendingItemIDs(before)) > 0 && len(pendingItemIDs(after)) == 0 {
+		delete(after, completedKey)
+	}
+}
+
+// completeCategoryHandler handles POST and DELETE
+// /data/categories/{name}/complete, marking a category as walked, so the
+// web client can collapse it, or unmarking it. Nested categories are named
+// by their path, e.g. /data/categories/Food/Dairy/complete. The marks are
+// kept in the document's completedCategories and cleared when the pending
+// list is emptied. The response lists the completed categories.
+func completeCategoryHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		category := categoryPath(mux.Vars(r)["name"])
+		if category == "" {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "Category name is required")
+			return
+		}
+		var completed map[string]bool
+		err := s.update(r.Context(), func(data JSONData) error {
+			completed = completedCategories(data)
+			if completed[category] == (r.Method == http.MethodPost) {
+				return errUnchanged
+			}
+			if r.Method == http.MethodPost {
+				completed[category] = true
+			} else {
+				delete(completed, category)
+			}
+			setCompletedCategories(data, completed)
+			return nil
+		})
+		writeCompletedResult(w, r, completed, err)
+	}
+}
+
+// clearCompletedHandler handles DELETE /data/categories/completed,
+// unmarking every completed category.
+func clearCompletedHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		err := s.update(r.Context(), func(data JSONData) error {
+			if _, ok := data[completedKey]; !ok {
+				return errUnchanged
+			}
+			delete(data, completedKey)
+			return nil
+		})
+		writeCompletedResult(w, r, map[string]bool{}, err)
+	}
+}
+
+// writeCompletedResult replies with the completed categories, or the error
+// of the write.
+func writeCompletedResult(w http.ResponseWriter, r *http.Request, completed map[string]bool, err error) {
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	categories := []string{}
+	for category := range completed {
+		categories = append(categories, category)
+	}
+	slices.Sort(categories)
+	writeJSON(w, http.StatusOK, map[string]interface{}{completedKey: categories})
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The path where the JSON data will be stored persistently.
+const dataFilePath = "data.json"
+
+// Config holds the runtime settings of the server, populated from
+// command-line flags and, for some settings, environment variables.
+type Config struct {
+	Port     string
+	DataFile string
+	// DataDirWait is how long startup waits for the directory of DataFile
+	// to appear, as with a volume that is mounted late; zero fails at once.
+	DataDirWait time.Duration
+	// MirrorFile, when set, receives a copy of every data file write and is
+	// used at startup when the data file is missing or corrupt.
+	MirrorFile string
+	// PurchasesFile stores how often each item has been bought.
+	PurchasesFile string
+	// ChangesFile stores the feed served by GET /data/changes, which
+	// retains the last MaxChanges entries.
+	ChangesFile string
+	MaxChanges  int
+	// DefaultsFile, when set, holds default field values for new items.
+	DefaultsFile string
+	// SectionsFile stores the category to aisle order mapping.
+	SectionsFile string
+	// PreferencesFile stores the UI preferences of GET and PUT /settings.
+	// RejectUnknownPreferences rejects keys outside knownPreferences
+	// instead of dropping them.
+	PreferencesFile          string
+	RejectUnknownPreferences bool
+	// Fsync syncs data file writes to the disk before reporting them as
+	// saved.
+	Fsync bool
+	// TrailingNewline ends the data file with a newline, as editors and
+	// git expect of text files.
+	TrailingNewline bool
+	// RepairOnStart normalizes the data file before serving.
+	RepairOnStart bool
+
+	// APIPort and StaticPort, when both set, serve the API and the website
+	// on separate listeners instead of the combined one on Port.
+	APIPort         string
+	StaticPort      string
+	ShutdownTimeout time.Duration
+	// QuotaFile keeps the request counts of the daily quota, see
+	// Reloadable.DailyQuota.
+	QuotaFile string
+	// TrustedProxies are the networks of reverse proxies, such as the
+	// ingress controller, whose X-Forwarded-For names the client; see
+	// clientIP.
+	TrustedProxies []*net.IPNet
+	// H2C accepts cleartext HTTP/2 alongside HTTP/1.1.
+	H2C bool
+	// Debug enables debug logging and development aids such as delay
+	// injection, which delays every response by Reloadable.InjectDelay or
+	// by the request's ?delay=<ms>.
+	Debug bool
+	// ServerTiming reports the lock wait, disk read/write and serialization
+	// time of API requests in a Server-Timing header.
+	ServerTiming bool
+
+	// WriteQueue caps the writes waiting for the one in progress; zero
+	// means unlimited. Writes beyond that are rejected with 503.
+	WriteQueue int
+
+	// DemoReset, when positive, replaces the list with DemoTemplate at
+	// that interval, for public demo instances. It has no environment
+	// variable, so it can only be turned on explicitly.
+	DemoReset    time.Duration
+	DemoTemplate string
+
+	// ExpiryInterval is how often expired items are removed; zero disables
+	// the cleanup.
+	ExpiryInterval time.Duration
+
+	// DecodeUploads strips byte order marks and converts UTF-16 request
+	// bodies to UTF-8 before they are parsed.
+	DecodeUploads bool
+
+	// StrictJSON rejects request bodies with fields outside the schema.
+	StrictJSON bool
+	// RejectDuplicateKeys rejects request bodies that repeat a key within
+	// an object instead of silently keeping the last value.
+	RejectDuplicateKeys bool
+	// AllowMethodOverride lets POST requests carry X-HTTP-Method-Override
+	// to be handled as PUT, PATCH or DELETE.
+	AllowMethodOverride bool
+	// ETagSecret, when set, signs ETags with an HMAC so they can't be
+	// computed for a modified body.
+	ETagSecret string
+	// AllowArrayRoot accepts a JSON array written as the whole document,
+	// taking it as the catalog, instead of rejecting it.
+	AllowArrayRoot bool
+	// StrictFields rejects catalog items with fields outside the Item
+	// schema with 422, naming the unknown field.
+	StrictFields bool
+
+	// ItemIDs selects how ids are generated for new items: "random" uses
+	// the web client's id-<millis>-<n> format, "slug" derives a readable
+	// key from the item name and "uuid" uses random UUIDs.
+	ItemIDs string
+	// NormalizeNames trims item names and collapses their whitespace on
+	// write, applying NameCase ("lower", "title" or "keep"). With
+	// KeepOriginalName the name as sent is kept in originalName.
+	NormalizeNames   bool
+	NameCase         string
+	KeepOriginalName bool
+	// AutocompleteLimit is the default and largest number of names
+	// GET /autocomplete returns.
+	AutocompleteLimit int
+	// BlankNames is what whole-document writes do with catalog items whose
+	// name is empty or only whitespace: "allow" them, "reject" the write or
+	// "drop" the items.
+	BlankNames string
+	// QuantityDecimals and DecimalSeparator format quantities in rendered
+	// exports such as GET /export.html: a fixed number of decimals, or -1
+	// for as many as needed, and "." or ",". API responses keep the stored
+	// numbers.
+	QuantityDecimals int
+	DecimalSeparator string
+	// SortTieBreaker orders items the requested sort considers equal:
+	// "id", "name" or "catalog" to keep the catalog order.
+	SortTieBreaker string
+	// PatchConflicts selects how PATCH /data/items/{id} treats concurrent
+	// changes: "merge" applies every patch in turn, so the last change to a
+	// field wins, "test" requires JSON Patches that test each field they
+	// change and rejects them when it changed meanwhile.
+	PatchConflicts string
+	// MaxQuantity, when positive, is the largest quantity an item or
+	// pending entry may be written with. RejectNegativeQuantities rejects
+	// quantities below zero.
+	MaxQuantity              float64
+	RejectNegativeQuantities bool
+	// MaxStringLength, when positive, is the most characters any string
+	// in the document may have.
+	MaxStringLength int
+	// UniqueBy, when not empty, lists the item fields that together must be
+	// unique across the catalog, such as name and unit.
+	UniqueBy []string
+	// IDFormat, when set, is a pattern every item id must match. The ids
+	// generated according to ItemIDs are checked against it at startup.
+	IDFormat *regexp.Regexp
+
+	// ColorPalette lists the named colors accepted for an item's color,
+	// in addition to hex codes.
+	ColorPalette []string
+	// CanonicalizeValues coerces boolean fields sent as strings or numbers
+	// to booleans and drops typed fields sent as null, before validation.
+	CanonicalizeValues bool
+	// FieldTypes maps catalog item fields to the JSON type they must have
+	// when present.
+	FieldTypes map[string]string
+
+	// DegradeOnWriteFailure puts the store in read-only mode when a write
+	// fails, probing every ReadOnlyProbeInterval until the disk recovers.
+	DegradeOnWriteFailure bool
+	ReadOnlyProbeInterval time.Duration
+
+	// URL paths of the PWA assets that get dedicated response headers.
+	ManifestPath      string
+	ServiceWorkerPath string
+	FaviconPath       string
+
+	// SecurityHeaders enables the default security response headers;
+	// Headers adds, overrides or (with an empty value) removes headers.
+	SecurityHeaders bool
+	Headers         headerFlag
+	// ContentSecurityPolicy is sent with the static site when
+	// SecurityHeaders is on; empty disables it.
+	ContentSecurityPolicy string
+
+	// live holds the settings POST /admin/reload can change while the
+	// server runs; read them through Live.
+	live atomic.Pointer[Reloadable]
+	// quotaEnabled tells whether the server started with a daily quota.
+	quotaEnabled bool
+}
+
+// Reloadable holds the settings that POST /admin/reload applies to the
+// running server. A reload swaps in a new Reloadable as a whole, so readers
+// always see a consistent set; they must call Config.Live for every use
+// instead of keeping the result.
+type Reloadable struct {
+	// AdminToken is the bearer token required by the /admin endpoints,
+	// which are disabled while it is empty.
+	AdminToken string
+	// DailyQuota limits the requests per client IP and UTC day; zero
+	// disables it. Enabling or disabling the quota takes a restart, changing
+	// the limit does not.
+	DailyQuota int
+	// SlowRequestThreshold is the duration above which a request is logged
+	// as a warning.
+	SlowRequestThreshold time.Duration
+	// InjectDelay delays every response in -debug mode.
+	InjectDelay time.Duration
+	// ItemTTL, when positive, is the lifetime given to new items that don't
+	// set an explicit expiresAt.
+	ItemTTL time.Duration
+	// WriteRetries is how many times a transient write failure is retried
+	// before the save is reported as failed.
+	WriteRetries int
+	// WriteRetryBackoff is the delay before the first retry; it doubles on
+	// every subsequent attempt.
+	WriteRetryBackoff time.Duration
+	// ImportURLHosts, when not empty, lists the only hosts POST /import-url
+	// may fetch from. Without it any public host is allowed, but never a
+	// loopback, private or link-local address. ImportURLTimeout and
+	// ImportURLMaxBytes bound the fetch.
+	ImportURLHosts    []string
+	ImportURLTimeout  time.Duration
+	ImportURLMaxBytes int64
+	// DataCacheControl is the Cache-Control sent with GET /data; empty
+	// sends none.
+	DataCacheControl string
+	// DataCacheTTL, when positive, is how long the serialized GET /data
+	// response is kept in memory and served again. Any write drops it.
+	DataCacheTTL time.Duration
+}
+
+// Live returns the current reloadable settings.
+func (c *Config) Live() *Reloadable {
+	return c.live.Load()
+}
+
+// parseConfig reads the configuration from the command-line flags, exiting
+// on invalid settings.
+func parseConfig() *Config {
+	c, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	return c
+}
+
+// loadConfig parses args with the flags defined on fs. Some settings take
+// their default from an environment variable, which is noted in the flag's
+// usage text; those variables can also be set in the file named by ENV_FILE,
+// which is read again on every reload.
+func loadConfig(fs *flag.FlagSet, args []string) (*Config, error) {
+	if err := loadEnvFile(os.Getenv("ENV_FILE")); err != nil {
+		return nil, err
+	}
+
+	c := &Config{Headers: headerFlag{}}
+	live := &Reloadable{}
+	fs.StringVar(&c.Port, "port", "80", "port to listen on")
+	fs.StringVar(&c.APIPort, "api-port", "", "serve the API on its own port (requires -static-port)")
+	fs.StringVar(&c.StaticPort, "static-port", "", "serve the website on its own port (requires -api-port)")
+	fs.IntVar(&live.DailyQuota, "daily-quota", envInt("DAILY_QUOTA", 0), "maximum requests per client IP and day, 0 for unlimited (env DAILY_QUOTA)")
+	fs.StringVar(&live.AdminToken, "admin-token", envString("ADMIN_TOKEN", ""), "bearer token for the /admin endpoints, empty to disable them (env ADMIN_TOKEN)")
+	fs.StringVar(&c.QuotaFile, "quota-file", "quota.json", "path of the JSON file keeping the daily request counts, by default next to the data file")
+	trustedProxies := fs.String("trusted-proxies", envString("TRUSTED_PROXIES", ""), "comma separated IPs or CIDR networks of reverse proxies whose X-Forwarded-For header names the client, e.g. 10.42.0.0/16; empty to count the connection's address (env TRUSTED_PROXIES)")
+	fs.BoolVar(&c.H2C, "h2c", false, "accept cleartext HTTP/2 (h2c) connections")
+	fs.BoolVar(&c.Debug, "debug", false, "log at debug level and allow delaying responses with ?delay=<ms>; for development only")
+	fs.DurationVar(&live.InjectDelay, "inject-delay", 0, "delay every response by this long to test loading states; requires -debug")
+	fs.BoolVar(&c.ServerTiming, "server-timing", envBool("SERVER_TIMING", false), "report lock wait, disk and serialization time of API requests in a Server-Timing header (env SERVER_TIMING)")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", 10*time.Second, "time allowed for in-flight requests on shutdown")
+	fs.DurationVar(&live.SlowRequestThreshold, "slow-request-threshold", envDuration("SLOW_REQUEST_THRESHOLD", 5*time.Second), "log a warning for requests slower than this (env SLOW_REQUEST_THRESHOLD)")
+	fs.StringVar(&c.DataFile, "data-file", dataFilePath, "path of the JSON data file")
+	fs.DurationVar(&c.DataDirWait, "data-dir-wait", envDuration("DATA_DIR_WAIT", 0), "wait up to this long at startup for the directory of the data file to appear, e.g. a network volume mounted late (env DATA_DIR_WAIT)")
+	fs.StringVar(&c.MirrorFile, "mirror-file", envString("MIRROR_FILE", ""), "path of a copy of the data file kept on every write, ideally on another disk (env MIRROR_FILE)")
+	fs.StringVar(&c.PurchasesFile, "purchases-file", "purchases.json", "path of the JSON purchase history file, by default next to the data file")
+	fs.StringVar(&c.ChangesFile, "changes-file", "changes.json", "path of the JSON changes feed file, by default next to the data file")
+	fs.IntVar(&c.MaxChanges, "max-changes", 1000, "number of entries retained in the changes feed")
+	fs.StringVar(&c.DefaultsFile, "defaults-file", envString("DEFAULTS_FILE", ""), "path of a JSON object with default field values for new items (env DEFAULTS_FILE)")
+	fs.StringVar(&c.SectionsFile, "sections-file", "sections.json", "path of the JSON store sections file, by default next to the data file")
+	fs.StringVar(&c.PreferencesFile, "preferences-file", "preferences.json", "path of the JSON UI preferences file, by default next to the data file")
+	fs.BoolVar(&c.RejectUnknownPreferences, "reject-unknown-preferences", false, "reject unknown keys in PUT /settings instead of dropping them")
+	fs.BoolVar(&c.TrailingNewline, "trailing-newline", envBool("TRAILING_NEWLINE", true), "end the data file with a newline, for cleaner git diffs (env TRAILING_NEWLINE)")
+	fs.BoolVar(&c.Fsync, "fsync", envBool("FSYNC", true), "sync every data file write to the disk before confirming it; turning it off is faster on slow disks but a crash can lose recent changes (env FSYNC)")
+	fs.BoolVar(&c.RepairOnStart, "repair-on-start", false, "normalize the data file into its canonical shape before serving")
+	fs.IntVar(&c.WriteQueue, "write-queue", envInt("WRITE_QUEUE", 0), "maximum writes waiting for the one in progress, beyond which they are rejected with 503; 0 for unlimited (env WRITE_QUEUE)")
+	fs.DurationVar(&live.ItemTTL, "item-ttl", envDuration("ITEM_TTL", 0), "default lifetime of new items without an explicit expiresAt, 0 to never expire (env ITEM_TTL)")
+	fs.DurationVar(&c.DemoReset, "demo-reset", 0, "for public demo instances only: replace the whole list with -demo-template at this interval, e.g. 1h; 0 to never reset")
+	fs.StringVar(&c.DemoTemplate, "demo-template", "", "JSON document the list is reset to by -demo-reset")
+	fs.DurationVar(&c.ExpiryInterval, "expiry-interval", time.Minute, "interval between removals of expired items, 0 to disable")
+	fs.IntVar(&live.WriteRetries, "write-retries", 3, "number of retries for transient data file write errors")
+	fs.DurationVar(&live.WriteRetryBackoff, "write-retry-backoff", 100*time.Millisecond, "initial delay between data file write retries")
+	importURLHosts := fs.String("import-url-hosts", envString("IMPORT_URL_HOSTS", ""), "comma separated hosts POST /import-url may fetch from, empty for any public host (env IMPORT_URL_HOSTS)")
+	fs.DurationVar(&live.ImportURLTimeout, "import-url-timeout", 10*time.Second, "timeout for fetching the document of POST /import-url")
+	fs.Int64Var(&live.ImportURLMaxBytes, "import-url-max-bytes", 1<<20, "maximum size of the document fetched by POST /import-url")
+	fs.BoolVar(&c.DecodeUploads, "decode-uploads", true, "strip byte order marks and convert UTF-16 uploads to UTF-8")
+	fs.BoolVar(&c.StrictJSON, "strict-json", false, "reject request bodies containing fields that are not part of the schema")
+	fs.BoolVar(&c.AllowMethodOverride, "allow-method-override", envBool("ALLOW_METHOD_OVERRIDE", false), "handle POST requests with an X-HTTP-Method-Override header of PUT, PATCH or DELETE as that method, for clients that can only send GET and POST (env ALLOW_METHOD_OVERRIDE)")
+	fs.StringVar(&c.ETagSecret, "etag-secret", envString("ETAG_SECRET", ""), "secret to sign ETags with (HMAC-SHA256), so clients caching responses on untrusted storage can't pass off a modified body; empty for plain content hashes (env ETAG_SECRET)")
+	fs.BoolVar(&c.AllowArrayRoot, "allow-array-root", envBool("ALLOW_ARRAY_ROOT", false), `accept a JSON array written as the whole list as its catalog, {"catalog": [...]}, instead of rejecting it (env ALLOW_ARRAY_ROOT)`)
+	fs.BoolVar(&c.RejectDuplicateKeys, "reject-duplicate-keys", false, "reject request bodies containing duplicate keys within a JSON object")
+	fs.BoolVar(&c.StrictFields, "strict-fields", envBool("STRICT_FIELDS", false), "reject catalog items with fields that are not part of the item schema (env STRICT_FIELDS)")
+	fs.StringVar(&c.ItemIDs, "item-ids", "random", `id generation for new items: "random", "slug" or "uuid"`)
+	fs.BoolVar(&c.NormalizeNames, "normalize-names", envBool("NORMALIZE_NAMES", false), "trim item names and collapse repeated whitespace when they are written (env NORMALIZE_NAMES)")
+	fs.IntVar(&c.AutocompleteLimit, "autocomplete-limit", envInt("AUTOCOMPLETE_LIMIT", 10), "default and largest number of names returned by GET /autocomplete (env AUTOCOMPLETE_LIMIT)")
+	fs.StringVar(&c.BlankNames, "blank-names", envString("BLANK_NAMES", "allow"), `what writing the whole list does with items whose name is blank: "allow", "reject" or "drop" them (env BLANK_NAMES)`)
+	fs.IntVar(&c.QuantityDecimals, "quantity-decimals", envInt("QUANTITY_DECIMALS", -1), "decimals shown for quantities in exports such as /export.html, -1 for as many as needed (env QUANTITY_DECIMALS)")
+	fs.StringVar(&c.DecimalSeparator, "decimal-separator", envString("DECIMAL_SEPARATOR", "."), `decimal separator of quantities in exports such as /export.html: "." or "," (env DECIMAL_SEPARATOR)`)
+	fs.StringVar(&c.NameCase, "name-case", "keep", `casing applied to item names by -normalize-names: "keep", "lower" or "title"`)
+	fs.BoolVar(&c.KeepOriginalName, "keep-original-name", false, "keep the name as sent in originalName when -normalize-names changes it")
+	fs.StringVar(&c.SortTieBreaker, "sort-tie-breaker", "id", `order of items that sort equally, so listings are reproducible: "id", "name" or "catalog" (catalog order)`)
+	fs.StringVar(&c.PatchConflicts, "patch-conflicts", "merge", `handling of concurrent PATCHes to the same item field: "merge" (last change wins) or "test" (require JSON Patch test operations and reject stale ones)`)
+	fs.IntVar(&c.MaxStringLength, "max-string-length", envInt("MAX_STRING_LENGTH", 0), "most characters any string in the list may have, 0 for no limit (env MAX_STRING_LENGTH)")
+	fs.Float64Var(&c.MaxQuantity, "max-quantity", envFloat("MAX_QUANTITY", 0), "largest quantity an item or list entry may be written with, to catch typos such as 1000; 0 for no limit (env MAX_QUANTITY)")
+	fs.BoolVar(&c.RejectNegativeQuantities, "reject-negative-quantities", envBool("REJECT_NEGATIVE_QUANTITIES", false), "reject negative item and list entry quantities (env REJECT_NEGATIVE_QUANTITIES)")
+	uniqueBy := fs.String("unique-by", envString("UNIQUE_BY", ""), `comma separated item fields whose values together must be unique in the catalog, e.g. "name,unit"; empty to allow duplicates (env UNIQUE_BY)`)
+	idFormat := fs.String("id-format", "", "regular expression item ids must match, empty to accept any id")
+	palette := fs.String("color-palette", "red,orange,yellow,green,blue,purple,pink,gray", "comma separated color names allowed for items besides hex codes")
+	fieldTypes := fs.String("field-types", "id:string,name:string,imageUrl:string,tags:array,color:string,archived:bool", "comma separated field:type pairs enforced on items (types: string, number, bool, array, object)")
+	fs.BoolVar(&c.CanonicalizeValues, "canonicalize-values", envBool("CANONICALIZE_VALUES", false), `on write, coerce bool fields sent as "true", 1, ... to booleans and drop typed fields sent as null (env CANONICALIZE_VALUES)`)
+	fs.BoolVar(&c.DegradeOnWriteFailure, "degrade-on-write-failure", true, "switch to read-only mode when data file writes fail")
+	fs.DurationVar(&c.ReadOnlyProbeInterval, "read-only-probe-interval", 30*time.Second, "interval between probe writes while in read-only mode")
+	fs.StringVar(&c.ManifestPath, "manifest-path", "/manifest.json", "URL path of the web app manifest")
+	fs.StringVar(&c.ServiceWorkerPath, "service-worker-path", "/sw.js", "URL path of the service worker script")
+	fs.StringVar(&c.FaviconPath, "favicon-path", "/favicon.ico", "URL path of the favicon")
+	fs.BoolVar(&c.SecurityHeaders, "security-headers", true, "send default security headers (X-Content-Type-Options, X-Frame-Options, Referrer-Policy, and Content-Security-Policy on the website)")
+	fs.StringVar(&c.ContentSecurityPolicy, "content-security-policy", defaultContentSecurityPolicy, "Content-Security-Policy of the website, empty to disable")
+	fs.DurationVar(&live.DataCacheTTL, "data-cache-ttl", envDuration("DATA_CACHE_TTL", 0), "keep the serialized GET /data response in memory this long for bursts of reads, 0 to serialize every response (env DATA_CACHE_TTL)")
+	fs.StringVar(&live.DataCacheControl, "data-cache-control", envString("DATA_CACHE_CONTROL", "no-store"), `Cache-Control of GET /data, e.g. "max-age=60" for lists served through a CDN, empty to send none (env DATA_CACHE_CONTROL)`)
+	fs.Var(c.Headers, "header", `response header as "Name: value", repeatable; "Name:" removes a default header`)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// The files kept alongside the list default to the directory of the
+	// data file, so moving it onto a persistent volume moves them too.
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, path := range map[string]*string{
+		"quota-file":       &c.QuotaFile,
+		"purchases-file":   &c.PurchasesFile,
+		"changes-file":     &c.ChangesFile,
+		"sections-file":    &c.SectionsFile,
+		"preferences-file": &c.PreferencesFile,
+	} {
+		if !set[name] {
+			*path = filepath.Join(filepath.Dir(c.DataFile), *path)
+		}
+	}
+
+	c.FieldTypes = map[string]string{}
+	for _, pair := range strings.Split(*fieldTypes, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		field, typ, ok := strings.Cut(pair, ":")
+		if !ok || !slices.Contains(knownFieldTypes, typ) {
+			return nil, fmt.Errorf("invalid -field-types entry %q, expected field:type with type one of %s", pair, strings.Join(knownFieldTypes, ", "))
+		}
+		c.FieldTypes[field] = typ
+	}
+	for _, host := range strings.Split(*importURLHosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			live.ImportURLHosts = append(live.ImportURLHosts, host)
+		}
+	}
+	for _, proxy := range strings.Split(*trustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy == "" {
+			continue
+		}
+		// A single address is a network of one.
+		cidr := proxy
+		if ip := net.ParseIP(proxy); ip != nil {
+			cidr = ip.String() + "/128"
+			if ip.To4() != nil {
+				cidr = ip.String() + "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -trusted-proxies entry %q, expected an IP or a CIDR network", proxy)
+		}
+		c.TrustedProxies = append(c.TrustedProxies, network)
+	}
+	for _, field := range strings.Split(*uniqueBy, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			c.UniqueBy = append(c.UniqueBy, field)
+		}
+	}
+	for _, name := range strings.Split(*palette, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			c.ColorPalette = append(c.ColorPalette, name)
+		}
+	}
+
+	if live.InjectDelay > 0 && !c.Debug {
+		return nil, fmt.Errorf("-inject-delay requires -debug")
+	}
+	if !slices.Contains([]string{"random", "slug", "uuid"}, c.ItemIDs) {
+		return nil, fmt.Errorf(`invalid -item-ids %q, expected "random", "slug" or "uuid"`, c.ItemIDs)
+	}
+	if !slices.Contains(itemTieBreakers, c.SortTieBreaker) {
+		return nil, fmt.Errorf(`invalid -sort-tie-breaker %q, expected "id", "name" or "catalog"`, c.SortTieBreaker)
+	}
+	if !slices.Contains([]string{"merge", "test"}, c.PatchConflicts) {
+		return nil, fmt.Errorf(`invalid -patch-conflicts %q, expected "merge" or "test"`, c.PatchConflicts)
+	}
+	if c.DemoReset < 0 {
+		return nil, fmt.Errorf("invalid -demo-reset %s, expected a positive interval or 0", c.DemoReset)
+	}
+	if c.DemoReset > 0 {
+		if c.DemoTemplate == "" {
+			return nil, fmt.Errorf("-demo-reset requires -demo-template")
+		}
+		if _, err := loadDemoTemplate(c.DemoTemplate); err != nil {
+			return nil, fmt.Errorf("invalid -demo-template: %v", err)
+		}
+	}
+	if c.AutocompleteLimit < 1 {
+		return nil, fmt.Errorf("invalid -autocomplete-limit %d, expected 1 or more", c.AutocompleteLimit)
+	}
+	if !slices.Contains([]string{"allow", "reject", "drop"}, c.BlankNames) {
+		return nil, fmt.Errorf(`invalid -blank-names %q, expected "allow", "reject" or "drop"`, c.BlankNames)
+	}
+	if c.QuantityDecimals < -1 {
+		return nil, fmt.Errorf("invalid -quantity-decimals %d, expected -1 or more", c.QuantityDecimals)
+	}
+	if !slices.Contains([]string{".", ","}, c.DecimalSeparator) {
+		return nil, fmt.Errorf(`invalid -decimal-separator %q, expected "." or ","`, c.DecimalSeparator)
+	}
+	if !slices.Contains([]string{"keep", "lower", "title"}, c.NameCase) {
+		return nil, fmt.Errorf(`invalid -name-case %q, expected "keep", "lower" or "title"`, c.NameCase)
+	}
+	if *idFormat != "" {
+		re, err := regexp.Compile(*idFormat)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -id-format: %v", err)
+		}
+		c.IDFormat = re
+		// Server-generated ids must pass the same check as client ones.
+		if sample := generateItemID(c, JSONData{}, "Item"); !re.MatchString(sample) {
+			return nil, fmt.Errorf("ids generated by -item-ids %s, such as %q, don't match -id-format %s", c.ItemIDs, sample, re)
+		}
+	}
+	c.live.Store(live)
+	c.quotaEnabled = live.DailyQuota > 0
+	return c, nil
+}
+
+// envFile holds the variables read from ENV_FILE. They take precedence over
+// the process environment, which can't be changed from outside once the
+// server runs.
+var envFile map[string]string
+
+// loadEnvFile reads path, a file of NAME=value lines as used by Docker's
+// --env-file, into envFile. Blank lines and lines starting with # are
+// skipped. An empty path clears envFile.
+func loadEnvFile(path string) error {
+	vars := map[string]string{}
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading env file: %w", err)
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name, value, ok := strings.Cut(line, "=")
+			if !ok {
+				return fmt.Errorf("%s:%d: expected NAME=value", path, i+1)
+			}
+			vars[strings.TrimSpace(name)] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	envFile = vars
+	return nil
+}
+
+// lookupEnv returns the value of the variable name from ENV_FILE or, failing
+// that, from the process environment.
+func lookupEnv(name string) (string, bool) {
+	if v, ok := envFile[name]; ok {
+		return v, true
+	}
+	return os.LookupEnv(name)
+}
+
+// envString returns the value of the environment variable name, or def when
+// it is unset. An empty value is kept, so the variable can disable a setting
+// that has a default.
+func envString(name, def string) string {
+	if v, ok := lookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+// envBool returns the boolean value of the environment variable name, or def
+// when it is unset or not a valid boolean.
+func envBool(name string, def bool) bool {
+	if v, err := strconv.ParseBool(envString(name, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// envDuration returns the duration value of the environment variable name,
+// or def when it is unset or not a valid duration.
+func envDuration(name string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(envString(name, "")); err == nil {
+		return v
+	}
+	return def
+}
+
+// envFloat returns the numeric value of the environment variable name, or
+// def when it is unset or not a valid number.
+func envFloat(name string, def float64) float64 {
+	if v, err := strconv.ParseFloat(envString(name, ""), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// envInt returns the integer value of the environment variable name, or def
+// when it is unset or not a valid integer.
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(envString(name, "")); err == nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesDefaultNextToDataFile(t *testing.T) {
+	dir := t.TempDir()
+	fs := flag.NewFlagSet(t.Name(), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := loadConfig(fs, []string{"-data-file", filepath.Join(dir, "list.json"), "-changes-file", "feed.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ got, want string }{
+		{cfg.QuotaFile, filepath.Join(dir, "quota.json")},
+		{cfg.PurchasesFile, filepath.Join(dir, "purchases.json")},
+		{cfg.SectionsFile, filepath.Join(dir, "sections.json")},
+		{cfg.PreferencesFile, filepath.Join(dir, "preferences.json")},
+		// Paths set explicitly are kept as given.
+		{cfg.ChangesFile, "feed.json"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("file = %s, want %s", tc.got, tc.want)
+		}
+	}
+}
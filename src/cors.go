@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// corsAllowedHeaders are the request headers the web client may send
+// cross-origin.
+var corsAllowedHeaders = []string{"X-Requested-With", "Content-Type", "Authorization", methodOverrideHeader}
+
+// withCORS wraps the API router with the CORS policy used by the web client.
+// Preflights for API routes are answered with the methods of that route
+// only, so a read-only endpoint never advertises DELETE. Other OPTIONS
+// requests reach the API, which describes the route's methods.
+func withCORS(router *mux.Router) http.Handler {
+	headers := handlers.AllowedHeaders(corsAllowedHeaders)
+	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	origins := handlers.AllowedOrigins([]string{"*"})
+	cors := handlers.CORS(headers, methods, origins)(router)
+
+	preflights := preflightMethods(router)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			if r.Header.Get("Access-Control-Request-Method") == "" {
+				router.ServeHTTP(w, r)
+				return
+			}
+			var match mux.RouteMatch
+			if router.Match(r, &match) && match.Route != nil {
+				if tmpl, err := match.Route.GetPathTemplate(); err == nil {
+					if methods, ok := preflights[tmpl]; ok {
+						answerPreflight(w, r, methods)
+						return
+					}
+				}
+			}
+		}
+		cors.ServeHTTP(w, r)
+	})
+}
+
+// preflightMethods maps the path template of every registered API route to
+// the methods a preflight for it allows, as registered with the route (see
+// handle). Routes registered without methods, such as the static website,
+// keep the global CORS policy.
+func preflightMethods(router *mux.Router) map[string][]string {
+	preflights := map[string][]string{}
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		if methods, err := route.GetMethods(); err == nil {
+			preflights[tmpl] = append([]string{http.MethodOptions}, methods...)
+		}
+		return nil
+	})
+	return preflights
+}
+
+// answerPreflight replies to a CORS preflight for a route accepting methods.
+// A preflight for any other method gets 405, so the browser doesn't send
+// the actual request.
+func answerPreflight(w http.ResponseWriter, r *http.Request, methods []string) {
+	if !slices.Contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+	w.WriteHeader(http.StatusOK)
+}
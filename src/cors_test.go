@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestSite returns the API with the static website mounted behind it and
+// the CORS policy in front, as main serves them on a single port. The
+// website is an empty directory.
+func newTestSite(t *testing.T, args ...string) http.Handler {
+	t.Helper()
+	s := newTestStore(t, args...)
+	api := NewRouter(s.cfg, s)
+	api.PathPrefix("/").Handler(staticHandler(s.cfg, t.TempDir()))
+	return withCORS(api)
+}
+
+func TestPreflightMethodsFollowRegisteredRoutes(t *testing.T) {
+	site := newTestSite(t)
+
+	for _, tc := range []struct {
+		path, method string
+		status       int
+		allow        string
+	}{
+		{"/data", http.MethodPut, http.StatusOK, "OPTIONS, GET, POST, PUT"},
+		{"/data/touch", http.MethodPost, http.StatusOK, "OPTIONS, POST"},
+		{"/data/items/milk", http.MethodPatch, http.StatusOK, "OPTIONS, PATCH"},
+		{"/data/categories/Food/Dairy/complete", http.MethodDelete, http.StatusOK, "OPTIONS, POST, DELETE"},
+		{"/health", http.MethodDelete, http.StatusMethodNotAllowed, ""},
+	} {
+		req := httptest.NewRequest(http.MethodOptions, tc.path, nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", tc.method)
+		rec := httptest.NewRecorder()
+		site.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("preflight %s %s: status %d, want %d", tc.method, tc.path, rec.Code, tc.status)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tc.allow {
+			t.Errorf("preflight %s %s: Access-Control-Allow-Methods %q, want %q", tc.method, tc.path, got, tc.allow)
+		}
+	}
+}
+
+func TestRouteMethods(t *testing.T) {
+	site := newTestSite(t)
+
+	// Plain OPTIONS requests describe the route.
+	rec := mustDo(t, site, http.MethodOptions, "/data/items", "", http.StatusNoContent)
+	if got := rec.Header().Get("Allow"); got != "OPTIONS, GET, POST, DELETE" {
+		t.Errorf("Allow = %q, want OPTIONS, GET, POST, DELETE", got)
+	}
+
+	// Other methods get a 405 from the API instead of reaching the website.
+	rec = mustDo(t, site, http.MethodGet, "/data/touch", "", http.StatusMethodNotAllowed)
+	if got := rec.Header().Get("Allow"); got != "OPTIONS, POST" {
+		t.Errorf("Allow = %q, want OPTIONS, POST", got)
+	}
+	var body apiError
+	decodeBody(t, rec, &body)
+	if body.Code != codeMethodNotAllowed {
+		t.Errorf("error code %q, want %s", body.Code, codeMethodNotAllowed)
+	}
+}
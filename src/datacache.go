@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cachedData is a serialized GET /data response and the changes feed
+// version it shows.
+type cachedData struct {
+	version int64
+	body    *etagBody
+	expires time.Time
+}
+
+// dataCache keeps the last serialized GET /data response for
+// -data-cache-ttl, so bursts of reads share one file read and
+// serialization.
+type dataCache struct {
+	mu    sync.Mutex
+	entry *cachedData
+}
+
+// get returns the cached response unless there is none or it has expired.
+func (c *dataCache) get(now time.Time) *cachedData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entry == nil || !now.Before(c.entry.expires) {
+		return nil
+	}
+	return c.entry
+}
+
+// set caches a response.
+func (c *dataCache) set(entry *cachedData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry = entry
+}
+
+// invalidate drops the cached response. Store.write calls it on every
+// write, under the write lock.
+func (c *dataCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entry = nil
+}
+
+// cachedDataResponse returns the serialized GET /data response, from the
+// cache when it is still fresh, and whether it was. A fresh response is
+// built and cached under the read lock, so no write can slip in between
+// reading the document and caching it.
+func (s *Store) cachedDataResponse(ctx context.Context) (*cachedData, bool, error) {
+	if entry := s.dataCache.get(time.Now()); entry != nil {
+		return entry, true, nil
+	}
+
+	t := timingsFrom(ctx)
+	start := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t.since("lock", start)
+
+	data, err := s.timedRead(t)
+	if err != nil {
+		return nil, false, err
+	}
+	start = time.Now()
+	body, err := encodeETagBody(s.cfg, data)
+	t.since("serialize", start)
+	if err != nil {
+		return nil, false, err
+	}
+	entry := &cachedData{version: s.changes.latest(), body: body, expires: time.Now().Add(s.cfg.Live().DataCacheTTL)}
+	s.dataCache.set(entry)
+	return entry, false, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// loadItemDefaults reads the -defaults-file, an object of field values given
+// to new items that lack them, e.g. {"category": "Otros", "unit": "ud"}. The
+// file is read on every use, so edits apply without a restart.
+func (s *Store) loadItemDefaults() (JSONData, error) {
+	if s.defaults == nil {
+		return nil, nil
+	}
+	var defaults JSONData
+	if err := s.defaults.load(&defaults); err != nil {
+		return nil, err
+	}
+	if _, ok := defaults["id"]; ok {
+		return nil, errors.New("item defaults cannot set an id")
+	}
+	if err := validateItem(s.cfg, defaults); err != nil {
+		return nil, fmt.Errorf("invalid item defaults: %w", err)
+	}
+	return defaults, nil
+}
+
+// applyItemDefaults fills the fields a new item lacks from the defaults
+// file. Fields the item sets, even to null or an empty value, are kept. An
+// unreadable or invalid file is logged and skipped rather than failing the
+// write; it was valid at startup.
+func (s *Store) applyItemDefaults(item JSONData) {
+	defaults, err := s.loadItemDefaults()
+	if err != nil {
+		log.Printf("Error loading item defaults: %v", err)
+		return
+	}
+	for field, value := range defaults {
+		if _, present := item[field]; !present {
+			item[field] = copyJSON(value)
+		}
+	}
+}
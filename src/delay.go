@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxInjectedDelay caps ?delay=ms so a typo can't hang a request for hours.
+const maxInjectedDelay = time.Minute
+
+// delayMiddleware holds every response back by -inject-delay, or by the
+// ?delay=<ms> of the request, so frontend developers can see their loading
+// states. It is only installed in -debug mode. The wait ends early when the
+// client goes away.
+func delayMiddleware(cfg *Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			delay := cfg.Live().InjectDelay
+			if ms, err := strconv.Atoi(r.URL.Query().Get("delay")); err == nil && ms >= 0 {
+				delay = min(time.Duration(ms)*time.Millisecond, maxInjectedDelay)
+			}
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// demoState holds when the next demo reset is due, for GET /status.
+type demoState struct {
+	nextReset atomic.Pointer[time.Time]
+}
+
+// loadDemoTemplate reads the -demo-template document the list is reset to.
+// It is read on every reset, so the demo content can be edited without a
+// restart.
+func loadDemoTemplate(path string) (JSONData, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading demo template: %w", err)
+	}
+	var data JSONData
+	if err := json.Unmarshal(content, &data); err != nil || data == nil {
+		return nil, fmt.Errorf("demo template %s is not a JSON object", path)
+	}
+	return data, nil
+}
+
+// resetDemo replaces the list with the demo template every -demo-reset, so
+// a public demo instance never stays cluttered by its visitors for long. It
+// runs for the lifetime of the server. A template that can't be read skips
+// that reset and keeps the list as it is.
+func (s *Store) resetDemo() {
+	for {
+		next := time.Now().Add(s.cfg.DemoReset)
+		s.demo.nextReset.Store(&next)
+		time.Sleep(time.Until(next))
+
+		template, err := loadDemoTemplate(s.cfg.DemoTemplate)
+		if err == nil {
+			_, err = s.replace(context.Background(), template)
+		}
+		if err != nil {
+			log.Printf("Error resetting the demo list: %v", err)
+			continue
+		}
+		log.Printf("Reset the demo list to %s", s.cfg.DemoTemplate)
+	}
+}
+
+// nextDemoReset returns when the demo list is reset next, or nil when demo
+// resets are off.
+func (s *Store) nextDemoReset() *time.Time {
+	return s.demo.nextReset.Load()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+)
+
+// documentDiff lists what changed between two versions of the document.
+// Catalog items and pending entries are identified by their id as
+// "catalog/<id>" and "pendingList/<itemId>"; any other top-level field is
+// identified by its key.
+type documentDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// keyedArrays names the id field of the top-level arrays diffed per entry.
+var keyedArrays = map[string]string{
+	catalogKey: "id",
+	pendingKey: "itemId",
+}
+
+// diffDocuments compares two versions of the document. A nil before is
+// treated as empty.
+func diffDocuments(before, after JSONData) documentDiff {
+	d := documentDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	d.compare(flattenDocument(before), flattenDocument(after))
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+func (d *documentDiff) compare(before, after map[string]interface{}) {
+	for key, old := range before {
+		updated, ok := after[key]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, key)
+		case !reflect.DeepEqual(old, updated):
+			d.Changed = append(d.Changed, key)
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			d.Added = append(d.Added, key)
+		}
+	}
+}
+
+// flattenDocument indexes the document by diff key. A keyed array with an
+// entry that lacks a string id is indexed by the array's key as a whole.
+func flattenDocument(data JSONData) map[string]interface{} {
+	flat := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if entries, ok := keyedEntries(key, value); ok {
+			for id, entry := range entries {
+				flat[key+"/"+id] = entry
+			}
+			continue
+		}
+		flat[key] = value
+	}
+	return flat
+}
+
+// keyedEntries indexes the entries of a keyed array by their id.
+func keyedEntries(key string, value interface{}) (map[string]interface{}, bool) {
+	idField, keyed := keyedArrays[key]
+	raw, isArray := value.([]interface{})
+	if !keyed || !isArray {
+		return nil, false
+	}
+	entries := make(map[string]interface{}, len(raw))
+	for _, entry := range raw {
+		obj, _ := entry.(map[string]interface{})
+		id, ok := obj[idField].(string)
+		if !ok {
+			return nil, false
+		}
+		entries[id] = entry
+	}
+	return entries, true
+}
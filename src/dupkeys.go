@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// jsonFrame is an object or array being scanned by checkDuplicateKeys.
+type jsonFrame struct {
+	path string
+	// keys is nil for arrays.
+	keys    map[string]bool
+	wantKey bool
+	index   int
+}
+
+// checkDuplicateKeys scans a JSON body token by token and rejects objects that
+// repeat a key. encoding/json silently keeps the last occurrence, which hides
+// client bugs such as a form posting a field twice. The error names the path
+// of the repeated key, e.g. catalog[2].name.
+func checkDuplicateKeys(body []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var stack []*jsonFrame
+	var key string
+
+	// valuePath returns the path of the value about to be read and marks it
+	// as consumed in its parent.
+	valuePath := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		top := stack[len(stack)-1]
+		if top.keys != nil {
+			top.wantKey = true
+			if top.path == "" {
+				return key
+			}
+			return top.path + "." + key
+		}
+		top.index++
+		return top.path + "[" + strconv.Itoa(top.index-1) + "]"
+	}
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, &jsonFrame{path: valuePath(), keys: map[string]bool{}, wantKey: true})
+			case '[':
+				stack = append(stack, &jsonFrame{path: valuePath()})
+			default:
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			if top := len(stack) - 1; top >= 0 && stack[top].keys != nil && stack[top].wantKey {
+				if stack[top].keys[t] {
+					path := t
+					if stack[top].path != "" {
+						path = stack[top].path + "." + t
+					}
+					return fmt.Errorf("duplicate key %s", path)
+				}
+				stack[top].keys[t] = true
+				stack[top].wantKey = false
+				key = t
+				continue
+			}
+			valuePath()
+		default:
+			valuePath()
+		}
+	}
+}
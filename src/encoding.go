@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errUnsupportedEncoding is returned for uploads that are not valid UTF-8 and
+// cannot be converted to it.
+var errUnsupportedEncoding = errors.New("unsupported text encoding, please upload UTF-8 or UTF-16")
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF32LE = []byte{0xFF, 0xFE, 0x00, 0x00}
+	bomUTF32BE = []byte{0x00, 0x00, 0xFE, 0xFF}
+)
+
+// decodeUpload converts an uploaded body to UTF-8 before it is parsed.
+// Spreadsheet tools such as Excel commonly prefix exports with a byte order
+// mark or save them as UTF-16; both are handled here. UTF-16 without a BOM is
+// detected from the NUL bytes surrounding the leading ASCII character, which
+// is reliable for JSON and CSV payloads.
+func decodeUpload(body []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(body, bomUTF32LE), bytes.HasPrefix(body, bomUTF32BE):
+		return nil, errUnsupportedEncoding
+	case bytes.HasPrefix(body, bomUTF8):
+		body = body[len(bomUTF8):]
+	case bytes.HasPrefix(body, bomUTF16LE):
+		return decodeUTF16(body[len(bomUTF16LE):], binary.LittleEndian)
+	case bytes.HasPrefix(body, bomUTF16BE):
+		return decodeUTF16(body[len(bomUTF16BE):], binary.BigEndian)
+	case len(body) >= 2 && body[0] == 0 && body[1] != 0:
+		return decodeUTF16(body, binary.BigEndian)
+	case len(body) >= 2 && body[0] != 0 && body[1] == 0:
+		return decodeUTF16(body, binary.LittleEndian)
+	}
+
+	if !utf8.Valid(body) {
+		return nil, errUnsupportedEncoding
+	}
+	return body, nil
+}
+
+// decodeUTF16 converts UTF-16 text in the given byte order to UTF-8.
+func decodeUTF16(body []byte, order binary.ByteOrder) ([]byte, error) {
+	if len(body)%2 != 0 {
+		return nil, errUnsupportedEncoding
+	}
+	units := make([]uint16, len(body)/2)
+	for i := range units {
+		units[i] = order.Uint16(body[2*i:])
+	}
+	return []byte(string(utf16.Decode(units))), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Machine-readable error codes sent in the JSON body of every API error, so
+// clients can tell failures apart without parsing the message. The list is
+// documented in the README; codes are never renamed once published.
+const (
+	codeBadRequest           = "BAD_REQUEST"
+	codeInvalidJSON          = "INVALID_JSON"
+	codeInvalidParameter     = "INVALID_PARAMETER"
+	codeValidationFailed     = "VALIDATION_FAILED"
+	codeItemNotFound         = "ITEM_NOT_FOUND"
+	codeItemExists           = "ITEM_EXISTS"
+	codeDuplicateItem        = "DUPLICATE_ITEM"
+	codePatchConflict        = "PATCH_CONFLICT"
+	codeIncompatibleUnits    = "INCOMPATIBLE_UNITS"
+	codePreconditionRequired = "PRECONDITION_REQUIRED"
+	codeNotFound             = "NOT_FOUND"
+	codeUnauthorized         = "UNAUTHORIZED"
+	codeMethodNotAllowed     = "METHOD_NOT_ALLOWED"
+	codeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	codeURLNotAllowed        = "URL_NOT_ALLOWED"
+	codeFetchFailed          = "FETCH_FAILED"
+	codeQuotaExceeded        = "QUOTA_EXCEEDED"
+	codeStorageFull          = "STORAGE_FULL"
+	codeReadOnly             = "READ_ONLY"
+	codeWriteQueueFull       = "WRITE_QUEUE_FULL"
+	codeInvalidConfig        = "INVALID_CONFIG"
+	codeInternal             = "INTERNAL_ERROR"
+)
+
+// apiError is the JSON body of an API error response.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
+
+// writeError replies with a JSON error body. It takes the place of
+// http.Error for API routes. Response headers such as
+// X-Content-Type-Options are left to headersMiddleware, so -header applies
+// to errors as well.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, apiError{Code: code, Message: message, Status: status})
+}
+
+// writeStoreError replies to a failed store operation: the errors a write
+// can end with get their own status and code, anything else is logged and
+// reported as an internal error. A duplicate by -unique-by also returns the
+// item it conflicts with, a PATCH conflict the current item.
+func writeStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	var dup *duplicateItemError
+	var conflict *patchConflictError
+	switch {
+	case errors.As(err, &conflict):
+		writeJSON(w, http.StatusConflict, struct {
+			apiError
+			Current JSONData `json:"current"`
+		}{apiError{Code: codePatchConflict, Message: err.Error(), Status: http.StatusConflict}, conflict.current})
+	case errors.As(err, &dup):
+		writeJSON(w, http.StatusConflict, struct {
+			apiError
+			Conflict JSONData `json:"conflict"`
+		}{apiError{Code: codeDuplicateItem, Message: err.Error(), Status: http.StatusConflict}, dup.existing})
+	case errors.Is(err, errItemNotFound):
+		writeError(w, http.StatusNotFound, codeItemNotFound, "Item not found")
+	case errors.Is(err, errItemExists):
+		writeError(w, http.StatusConflict, codeItemExists, err.Error())
+	case errors.Is(err, errIncompatibleUnits):
+		writeError(w, http.StatusConflict, codeIncompatibleUnits, err.Error())
+	case errors.As(err, new(*validationError)):
+		writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
+	case errors.Is(err, errDiskFull):
+		writeError(w, http.StatusInsufficientStorage, codeStorageFull, "Insufficient Storage: "+errDiskFull.Error())
+	case errors.Is(err, errReadOnly):
+		writeError(w, http.StatusServiceUnavailable, codeReadOnly, "Service Unavailable: "+err.Error())
+	case errors.Is(err, errWriteQueueFull):
+		w.Header().Set("Retry-After", strconv.Itoa(writeQueueRetryAfter))
+		writeError(w, http.StatusServiceUnavailable, codeWriteQueueFull, "Service Unavailable: "+err.Error())
+	default:
+		log.Printf("Error in %s %s: %v", r.Method, r.URL.Path, err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestErrorResponseHeadersFollowConfig(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "nosniff"},
+		{[]string{"-header", "X-Content-Type-Options:"}, ""},
+		{[]string{"-security-headers=false"}, ""},
+	} {
+		s := newTestStore(t, tc.args...)
+		srv := newServer(s.cfg, "", withMethodOverride(s.cfg, withCORS(NewRouter(s.cfg, s))), nil)
+
+		for _, path := range []string{"/data/items/missing/archive", "/no-such-route"} {
+			rec := do(srv.Handler, http.MethodPost, path, "")
+			if rec.Code < http.StatusBadRequest {
+				t.Fatalf("POST %s: status %d, want an error", path, rec.Code)
+			}
+			if got := rec.Header().Get("X-Content-Type-Options"); got != tc.want {
+				t.Errorf("%v: POST %s: X-Content-Type-Options = %q, want %q", tc.args, path, got, tc.want)
+			}
+		}
+	}
+}
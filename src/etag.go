@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// computeETag returns a strong ETag from the SHA-256 of a serialized
+// response body. With -etag-secret it is an HMAC of the hash instead, so a
+// client that caches bodies on untrusted storage can't have a modified body
+// pass as current under a valid-looking ETag: only the server can compute
+// the ETag of a body, and If-None-Match is checked against that.
+func computeETag(cfg *Config, sum [sha256.Size]byte) string {
+	if cfg.ETagSecret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.ETagSecret))
+		mac.Write(sum[:])
+		return `"` + hex.EncodeToString(mac.Sum(nil)[:16]) + `"`
+	}
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// setChecksum sends the SHA-256 of the response body as X-Content-SHA256, so
+// clients can detect corruption in transit independently of TLS.
+func setChecksum(w http.ResponseWriter, sum [sha256.Size]byte) {
+	w.Header().Set("X-Content-SHA256", hex.EncodeToString(sum[:]))
+}
+
+// etagMatches reports whether the If-None-Match header lists etag (or "*").
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONWithETag serializes v and tags the response with an ETag computed
+// from exactly that body, so a view over a slice of the data (one tag, one
+// category, ...) revalidates independently of unrelated changes elsewhere in
+// the document. When the client already holds the current version it gets a
+// 304 Not Modified without a body.
+func writeJSONWithETag(cfg *Config, w http.ResponseWriter, r *http.Request, v interface{}) {
+	start := time.Now()
+	body, err := encodeETagBody(cfg, v)
+	timingsOf(w).since("serialize", start)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+		return
+	}
+	body.serve(w, r)
+}
+
+// etagBody is a serialized JSON response with its checksum and ETag, ready
+// to be sent any number of times.
+type etagBody struct {
+	body []byte
+	sum  [sha256.Size]byte
+	etag string
+}
+
+// encodeETagBody serializes v as writeJSONWithETag sends it. The body is
+// hashed once for both the ETag and the checksum.
+func encodeETagBody(cfg *Config, v interface{}) (*etagBody, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	body = append(body, '\n')
+	sum := sha256.Sum256(body)
+	return &etagBody{body: body, sum: sum, etag: computeETag(cfg, sum)}, nil
+}
+
+// serve sends the body, or a 304 Not Modified when the client already
+// holds it.
+func (b *etagBody) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", b.etag)
+	if etagMatches(r.Header.Get("If-None-Match"), b.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	setChecksum(w, b.sum)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b.body)))
+	w.Write(b.body)
+}
+
+// dataHashHandler handles GET /data/hash, returning the SHA-256 of the
+// document as GET /data serializes it (so it equals that response's
+// X-Content-SHA256), its ETag and the changes feed version it matches.
+// Sync clients can compare it with what they hold without downloading the
+// document.
+func dataHashHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, version, err := s.readVersioned(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /data/hash: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		body, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Error in GET /data/hash: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		sum := sha256.Sum256(append(body, '\n'))
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"sha256":  hex.EncodeToString(sum[:]),
+			"etag":    computeETag(s.cfg, sum),
+			"version": version,
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChecksumMatchesBody(t *testing.T) {
+	for _, args := range [][]string{nil, {"-data-cache-ttl", "1m"}, {"-etag-secret", "s3cret"}} {
+		_, api := newTestAPI(t, args...)
+		mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Leche", "tags": ["dairy"]}`, http.StatusCreated)
+
+		// The second GET /data is served from the cache with -data-cache-ttl.
+		for _, path := range []string{"/data", "/data", "/data/items", "/data/items?tag=dairy"} {
+			rec := mustDo(t, api, http.MethodGet, path, "", http.StatusOK)
+			sum := sha256.Sum256(rec.Body.Bytes())
+			if got := rec.Header().Get("X-Content-SHA256"); got != hex.EncodeToString(sum[:]) {
+				t.Errorf("%v: GET %s: X-Content-SHA256 = %q, want the hash of the body", args, path, got)
+			}
+		}
+
+		var hash map[string]interface{}
+		decodeBody(t, mustDo(t, api, http.MethodGet, "/data/hash", "", http.StatusOK), &hash)
+		if got := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK).Header().Get("X-Content-SHA256"); hash["sha256"] != got {
+			t.Errorf("%v: GET /data/hash sha256 = %v, want %s", args, hash["sha256"], got)
+		}
+	}
+}
+
+// getIfNoneMatch sends GET path with If-None-Match and returns the status.
+func getIfNoneMatch(api http.Handler, path, etag string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestSignedETags(t *testing.T) {
+	_, api := newTestAPI(t, "-etag-secret", "s3cret")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+	rec := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK)
+	etag := rec.Header().Get("ETag")
+
+	// The ETag is an HMAC only the server can compute, not the content hash.
+	sum := sha256.Sum256(rec.Body.Bytes())
+	unsigned := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if etag == unsigned {
+		t.Fatalf("ETag %s is the plain content hash", etag)
+	}
+
+	// A tampered ETag has one hex digit changed.
+	digit := byte('0')
+	if etag[len(etag)-2] == '0' {
+		digit = '1'
+	}
+	tampered := etag[:len(etag)-2] + string(digit) + `"`
+
+	for _, tc := range []struct {
+		name, etag string
+		want       int
+	}{
+		{"matching", etag, http.StatusNotModified},
+		{"weak matching", "W/" + etag, http.StatusNotModified},
+		{"non-matching", `"0123456789abcdef0123456789abcdef"`, http.StatusOK},
+		{"unsigned content hash", unsigned, http.StatusOK},
+		{"tampered", tampered, http.StatusOK},
+	} {
+		if got := getIfNoneMatch(api, "/data", tc.etag); got != tc.want {
+			t.Errorf("%s ETag: status %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	// Another secret signs the same body differently.
+	_, other := newTestAPI(t, "-etag-secret", "other")
+	mustDo(t, other, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+	if got := getIfNoneMatch(other, "/data", etag); got != http.StatusOK {
+		t.Errorf("ETag signed with another secret: status %d, want 200", got)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// eventsBuffer is how many events a subscriber may fall behind before it is
+// disconnected. It reconnects and starts over from a full sync.
+const eventsBuffer = 16
+
+// eventsKeepAlive is the interval of the comments sent on idle event
+// streams, so proxies don't close them.
+const eventsKeepAlive = 30 * time.Second
+
+// dataEvent is a serialized Server-Sent Event of GET /data/events.
+type dataEvent struct {
+	name    string
+	version int64
+	data    []byte
+}
+
+// eventBroker fans the deltas of the changes feed out to the subscribers of
+// GET /data/events.
+type eventBroker struct {
+	mu   sync.Mutex
+	subs map[chan dataEvent]struct{}
+}
+
+// newDataEvent serializes a delta as a "delta" event, or as a "sync" event
+// when it carries the whole document.
+func newDataEvent(delta dataDelta) (dataEvent, error) {
+	name := "delta"
+	if delta.Full {
+		name = "sync"
+	}
+	data, err := json.Marshal(delta)
+	if err != nil {
+		return dataEvent{}, err
+	}
+	return dataEvent{name: name, version: delta.Version, data: data}, nil
+}
+
+// subscribe registers a new subscriber. The returned func unregisters it.
+func (b *eventBroker) subscribe() (<-chan dataEvent, func()) {
+	ch := make(chan dataEvent, eventsBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = map[chan dataEvent]struct{}{}
+	}
+	b.subs[ch] = struct{}{}
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// active reports whether anyone is subscribed, so writes skip building
+// deltas nobody receives.
+func (b *eventBroker) active() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) > 0
+}
+
+// publish sends a delta to every subscriber. It is serialized once, before
+// the document it points into can change. Subscribers whose buffer is full
+// are dropped instead of blocking the write.
+func (b *eventBroker) publish(delta dataDelta) {
+	event, err := newDataEvent(delta)
+	if err != nil {
+		log.Printf("Error encoding data event: %v", err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribeEvents reads the document and subscribes to its changes under
+// the same read lock, so no write can fall between the initial sync and the
+// first delta.
+func (s *Store) subscribeEvents() (dataEvent, <-chan dataEvent, func(), error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := s.read()
+	if err != nil {
+		return dataEvent{}, nil, nil, err
+	}
+	initial, err := newDataEvent(dataDelta{Version: s.changes.latest(), Full: true, Data: data})
+	if err != nil {
+		return dataEvent{}, nil, nil, err
+	}
+	events, unsubscribe := s.events.subscribe()
+	return initial, events, unsubscribe, nil
+}
+
+// writeEvent writes one Server-Sent Event and flushes it to the client.
+func writeEvent(w http.ResponseWriter, event dataEvent) error {
+	_, err := fmt.Fprintf(w, "event: %s\nid: %d\ndata: %s\n\n", event.name, event.version, event.data)
+	if err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// eventsHandler handles GET /data/events, a Server-Sent Events stream of the
+// list. It starts with a "sync" event holding the whole document, then sends
+// a "delta" event for every change with only the entries it touched, in the
+// format of GET /data?sinceVersion (a dataDelta). A change that can't be
+// expressed per entry is sent as another "sync". Each event's id is the
+// version it brings the client to.
+//
+// The stream is refused on the transaction store of a POST /batch: it would
+// run under the batch's write lock for as long as the client stays
+// connected, and a transaction publishes no events anyway.
+func eventsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		if s.mem != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "The event stream is not available within a batch")
+			return
+		}
+
+		initial, events, unsubscribe, err := s.subscribeEvents()
+		if err != nil {
+			log.Printf("Error in GET /data/events: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		if err := writeEvent(w, initial); err != nil {
+			if !errors.Is(err, http.ErrNotSupported) {
+				log.Printf("Error in GET /data/events: %v", err)
+			}
+			return
+		}
+
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					// Fell too far behind; the client reconnects.
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				if err := http.NewResponseController(w).Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
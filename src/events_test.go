@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next Server-Sent Event from a stream, skipping
+// keep-alive comments.
+func readEvent(t *testing.T, r *bufio.Reader) (name string, data dataDelta) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+				t.Fatalf("decoding event data %q: %v", line, err)
+			}
+		case line == "" && name != "":
+			return name, data
+		}
+	}
+}
+
+func TestEventsSendDeltaAfterWrite(t *testing.T) {
+	_, api := newTestAPI(t)
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/data/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	if name, _ := readEvent(t, events); name != "sync" {
+		t.Fatalf("first event = %q, want sync", name)
+	}
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+
+	// A missing delta fails the read instead of hanging the test.
+	timer := time.AfterFunc(5*time.Second, func() { resp.Body.Close() })
+	defer timer.Stop()
+	name, delta := readEvent(t, events)
+	if name != "delta" {
+		t.Fatalf("event after the write = %q, want delta", name)
+	}
+	if delta.Full || len(delta.Upserted) != 1 {
+		t.Fatalf("delta = %+v, want the added item only", delta)
+	}
+	for _, v := range delta.Upserted {
+		if item, _ := v.(map[string]interface{}); item["name"] != "Milk" {
+			t.Fatalf("upserted %v, want the item Milk", v)
+		}
+	}
+}
+
+func TestEventsRefusedInTransaction(t *testing.T) {
+	s := newTestStore(t)
+	tx := &Store{cfg: s.cfg, mem: JSONData{}, changes: s.changes}
+
+	rec := httptest.NewRecorder()
+	eventsHandler(tx)(rec, httptest.NewRequest(http.MethodGet, "/data/events", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want %d; body: %s", rec.Code, http.StatusBadRequest, rec.Body)
+	}
+}
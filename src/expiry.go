@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// itemExpiry returns when the item expires, if it has a valid expiresAt.
+func itemExpiry(item JSONData) (time.Time, bool) {
+	raw, _ := item["expiresAt"].(string)
+	if raw == "" {
+		return time.Time{}, false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, raw)
+	return expiresAt, err == nil
+}
+
+// applyDefaultTTL sets expiresAt on a new item from -item-ttl. An explicit
+// expiresAt sent by the client always wins over the default.
+func applyDefaultTTL(cfg *Config, item JSONData, now time.Time) {
+	ttl := cfg.Live().ItemTTL
+	if ttl <= 0 {
+		return
+	}
+	if _, present := item["expiresAt"]; present {
+		return
+	}
+	item["expiresAt"] = now.Add(ttl).UTC().Format(time.RFC3339)
+}
+
+// expiredItemIDs returns the ids of the catalog items whose expiresAt has
+// passed.
+func expiredItemIDs(data JSONData, now time.Time) []string {
+	var ids []string
+	for _, item := range catalogItems(data) {
+		if expiresAt, ok := itemExpiry(item); ok && !now.Before(expiresAt) {
+			id, _ := item["id"].(string)
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// expireItems removes expired items (and their pending list entries) every
+// -expiry-interval. It runs for the lifetime of the server.
+func (s *Store) expireItems() {
+	for {
+		time.Sleep(s.cfg.ExpiryInterval)
+
+		var removed int
+		err := s.update(context.Background(), func(data JSONData) error {
+			ids := expiredItemIDs(data, time.Now())
+			if len(ids) == 0 {
+				return errUnchanged
+			}
+			removed = len(removeItems(data, ids))
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error removing expired items: %v", err)
+		} else if removed > 0 {
+			log.Printf("Removed %d expired item(s)", removed)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exportCategoryFallback is the heading of items without a category.
+const exportCategoryFallback = "Other"
+
+// exportPage is the data of exportTemplate.
+type exportPage struct {
+	Groups []exportGroup
+}
+
+// exportGroup is one category of the exported list.
+type exportGroup struct {
+	Category string
+	Entries  []exportEntry
+}
+
+// exportEntry is one line of the exported list.
+type exportEntry struct {
+	Name     string
+	Quantity string
+	Checked  bool
+}
+
+// exportTemplate renders GET /export.html. It is self-contained, with its
+// styles inline, so it can be saved or mailed and opened anywhere.
+var exportTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Shopping list</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 2rem auto; padding: 0 1rem; color: #1f2937; }
+h1 { font-size: 1.5rem; }
+h2 { font-size: 1rem; text-transform: uppercase; letter-spacing: .05em; color: #6b7280; border-bottom: 1px solid #e5e7eb; padding-bottom: .25rem; margin-top: 1.5rem; }
+ul { list-style: none; padding: 0; margin: 0; }
+li { padding: .35rem 0; }
+li.checked span { text-decoration: line-through; color: #9ca3af; }
+.quantity { color: #6b7280; }
+.empty { color: #6b7280; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>Shopping list</h1>
+{{- range .Groups}}
+<h2>{{.Category}}</h2>
+<ul>
+{{- range .Entries}}
+<li{{if .Checked}} class="checked"{{end}}><label><input type="checkbox"{{if .Checked}} checked{{end}}> <span>{{.Name}}{{if .Quantity}} <span class="quantity">× {{.Quantity}}</span>{{end}}</span></label></li>
+{{- end}}
+</ul>
+{{- else}}
+<p class="empty">Nothing to buy, the list is empty.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// formatQuantity renders a quantity for people, with -quantity-decimals
+// decimals and -decimal-separator, e.g. "1,5" for Spanish readers. It is
+// only meant for exports; the stored and JSON values stay plain numbers.
+func formatQuantity(cfg *Config, quantity float64) string {
+	text := strconv.FormatFloat(quantity, 'f', cfg.QuantityDecimals, 64)
+	if cfg.DecimalSeparator != "." {
+		text = strings.Replace(text, ".", cfg.DecimalSeparator, 1)
+	}
+	return text
+}
+
+// exportQuantity formats the quantity of a pending entry with the unit of
+// its item, or returns "" when the entry has no quantity.
+func exportQuantity(cfg *Config, ref map[string]interface{}, item JSONData) string {
+	quantity, ok := ref["quantity"].(float64)
+	if !ok {
+		return ""
+	}
+	text := formatQuantity(cfg, quantity)
+	if unit, _ := item["unit"].(string); strings.TrimSpace(unit) != "" {
+		text += " " + strings.TrimSpace(unit)
+	}
+	return text
+}
+
+// buildExportPage groups the pending list by category, with the categories
+// in store route order (see /settings/sections) and then by name. Entries
+// keep the pending list order within their category; entries whose item is
+// no longer in the catalog are left out.
+func buildExportPage(cfg *Config, data JSONData, sections map[string]int) exportPage {
+	groups := map[string]*exportGroup{}
+	raw, _ := data[pendingKey].([]interface{})
+	for _, entry := range raw {
+		ref, _ := entry.(map[string]interface{})
+		itemID, _ := ref["itemId"].(string)
+		item, err := findItem(data, itemID)
+		if err != nil {
+			continue
+		}
+		category, _ := item["category"].(string)
+		if strings.TrimSpace(category) == "" {
+			category = exportCategoryFallback
+		}
+		group, ok := groups[category]
+		if !ok {
+			group = &exportGroup{Category: category}
+			groups[category] = group
+		}
+		name, _ := item["name"].(string)
+		checked, _ := ref["checked"].(bool)
+		group.Entries = append(group.Entries, exportEntry{Name: name, Quantity: exportQuantity(cfg, ref, item), Checked: checked})
+	}
+
+	page := exportPage{Groups: make([]exportGroup, 0, len(groups))}
+	for _, group := range groups {
+		page.Groups = append(page.Groups, *group)
+	}
+	aisle := func(category string) int {
+		if order, ok := sections[category]; ok {
+			return order
+		}
+		return math.MaxInt
+	}
+	sort.Slice(page.Groups, func(i, j int) bool {
+		a, b := page.Groups[i].Category, page.Groups[j].Category
+		if aisle(a) != aisle(b) {
+			return aisle(a) < aisle(b)
+		}
+		return strings.ToLower(a) < strings.ToLower(b)
+	})
+	return page
+}
+
+// exportHTMLHandler handles GET /export.html, the pending list as a
+// standalone printable HTML page grouped by category, for sharing the list
+// with someone who doesn't use the app. Item names are escaped by
+// html/template.
+func exportHTMLHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /export.html: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		sections, err := s.loadSections()
+		if err != nil {
+			log.Printf("Error in GET /export.html: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := exportTemplate.Execute(&buf, buildExportPage(s.cfg, data, sections)); err != nil {
+			log.Printf("Error in GET /export.html: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
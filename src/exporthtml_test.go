@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFormatQuantity(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		quantity float64
+		want     string
+	}{
+		{nil, 1, "1"},
+		{nil, 1.5, "1.5"},
+		{nil, 0.25, "0.25"},
+		{[]string{"-quantity-decimals", "1"}, 1, "1.0"},
+		{[]string{"-quantity-decimals", "1"}, 0.26, "0.3"},
+		{[]string{"-decimal-separator", ","}, 1.5, "1,5"},
+		{[]string{"-decimal-separator", ","}, 2, "2"},
+		{[]string{"-decimal-separator", ",", "-quantity-decimals", "2"}, 1, "1,00"},
+		{[]string{"-quantity-decimals", "0"}, 2.5, "2"},
+	} {
+		cfg := newTestConfig(t, tc.args...)
+		if got := formatQuantity(cfg, tc.quantity); got != tc.want {
+			t.Errorf("%v: formatQuantity(%v) = %q, want %q", tc.args, tc.quantity, got, tc.want)
+		}
+	}
+}
+
+func TestQuantityFormatOnlyInExports(t *testing.T) {
+	_, api := newTestAPI(t, "-decimal-separator", ",")
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "ham", "name": "Jamón", "unit": "kg"}], "pendingList": [{"itemId": "ham", "quantity": 1.5}]}`, http.StatusOK)
+
+	if page := mustDo(t, api, http.MethodGet, "/export.html", "", http.StatusOK).Body.String(); !strings.Contains(page, "× 1,5 kg") {
+		t.Errorf("export page doesn't show the quantity as 1,5 kg:\n%s", page)
+	}
+	if data := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK).Body.String(); !strings.Contains(data, `"quantity":1.5`) {
+		t.Errorf("GET /data = %s, want the plain number 1.5", data)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// facetDimensions are the keys of GET /facets.
+var facetDimensions = []string{"category", "tag", "checked", "priority"}
+
+// facetValue returns the facet value of an item field, or "" when the item
+// has none.
+func facetValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// countFacets counts the active catalog items per value of each facet
+// dimension in a single pass. checked only counts the items on the pending
+// list, as "true" once in the cart and "false" before.
+func countFacets(data JSONData) map[string]map[string]int {
+	facets := make(map[string]map[string]int, len(facetDimensions))
+	for _, dimension := range facetDimensions {
+		facets[dimension] = map[string]int{}
+	}
+
+	checked := map[string]bool{}
+	raw, _ := data[pendingKey].([]interface{})
+	for _, entry := range raw {
+		ref, _ := entry.(map[string]interface{})
+		if itemID, ok := ref["itemId"].(string); ok {
+			isChecked, _ := ref["checked"].(bool)
+			checked[itemID] = checked[itemID] || isChecked
+		}
+	}
+
+	for _, item := range activeItems(data) {
+		if category := facetValue(item["category"]); category != "" {
+			facets["category"][category]++
+		}
+		if priority := facetValue(item["priority"]); priority != "" {
+			facets["priority"][priority]++
+		}
+		tags, _ := item["tags"].([]interface{})
+		seen := map[string]bool{}
+		for _, tag := range tags {
+			if value := facetValue(tag); value != "" && !seen[value] {
+				seen[value] = true
+				facets["tag"][value]++
+			}
+		}
+		id, _ := item["id"].(string)
+		if isChecked, pending := checked[id]; pending {
+			facets["checked"][strconv.FormatBool(isChecked)]++
+		}
+	}
+	return facets
+}
+
+// facetsHandler handles GET /facets, returning for each facet dimension
+// (category, tag, checked and priority) how many active items have each
+// value, so a filter sidebar can be built with a single request. Items
+// without a value are not counted in that dimension.
+func facetsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /facets: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		writeJSONWithETag(s.cfg, w, r, countFacets(data))
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestFacets(t *testing.T) {
+	// Strict modes must accept every field the facets count.
+	_, api := newTestAPI(t, "-strict-fields", "-strict-json")
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [
+		{"id": "a", "name": "Apples", "category": "Fruit", "priority": "high", "tags": ["bio"]},
+		{"id": "b", "name": "Pears", "category": "Fruit", "priority": 2},
+		{"id": "c", "name": "Soap", "priority": "high", "tags": ["bio", "bio"]},
+		{"id": "d", "name": "Old", "category": "Fruit", "archived": true}
+	], "pendingList": [
+		{"itemId": "a", "quantity": 1, "checked": true},
+		{"itemId": "b", "quantity": 1}
+	]}`, http.StatusOK)
+
+	var facets map[string]map[string]int
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/facets", "", http.StatusOK), &facets)
+	want := map[string]map[string]int{
+		"category": {"Fruit": 2},
+		"priority": {"high": 2, "2": 1},
+		"tag":      {"bio": 2},
+		"checked":  {"true": 1, "false": 1},
+	}
+	if !reflect.DeepEqual(facets, want) {
+		t.Fatalf("facets = %v, want %v", facets, want)
+	}
+}
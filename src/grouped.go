@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// pendingView is a pending list entry joined with its catalog item.
+type pendingView struct {
+	Item     JSONData `json:"item"`
+	Quantity float64  `json:"quantity"`
+	Checked  bool     `json:"checked"`
+	// CategoryCompleted is set when the item's category was marked as
+	// walked, see completeCategoryHandler.
+	CategoryCompleted bool `json:"categoryCompleted,omitempty"`
+}
+
+// groupedByCheckedHandler handles GET /data/grouped-by-checked, splitting
+// the pending list into what is still to buy ("unchecked") and what is
+// already in the cart ("checked"), each entry with its catalog item. An
+// entry counts as checked only when its checked field is true; a missing or
+// non-boolean field means unchecked. Entries whose item is no longer in the
+// catalog are left out. Both groups keep the pending list order.
+func groupedByCheckedHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /data/grouped-by-checked: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		groups := map[string][]pendingView{"unchecked": {}, "checked": {}}
+		completed := completedCategories(data)
+		raw, _ := data[pendingKey].([]interface{})
+		for _, entry := range raw {
+			ref, _ := entry.(map[string]interface{})
+			itemID, _ := ref["itemId"].(string)
+			item, err := findItem(data, itemID)
+			if err != nil {
+				continue
+			}
+			view := pendingView{Item: item}
+			view.Quantity, _ = ref["quantity"].(float64)
+			view.Checked, _ = ref["checked"].(bool)
+			view.CategoryCompleted = itemCategoryCompleted(item, completed)
+			if view.Checked {
+				groups["checked"] = append(groups["checked"], view)
+			} else {
+				groups["unchecked"] = append(groups["unchecked"], view)
+			}
+		}
+		writeJSONWithETag(s.cfg, w, r, groups)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// defaultSecurityHeaders are sent on every response unless disabled with
+// -security-headers=false or overridden with -header.
+var defaultSecurityHeaders = map[string]string{
+	"X-Content-Type-Options": "nosniff",
+	"X-Frame-Options":        "DENY",
+	"Referrer-Policy":        "strict-origin-when-cross-origin",
+}
+
+// defaultContentSecurityPolicy fits the bundled web client: its inline
+// scripts and the Tailwind CDN script, styles injected by Tailwind, item
+// images from any host and API calls to this server only.
+const defaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' https://cdn.tailwindcss.com; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src * data:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'none'"
+
+// headerFlag collects repeated -header "Name: value" flags. An empty value
+// ("Name:") removes a header that would otherwise be sent by default.
+type headerFlag map[string]string
+
+func (h headerFlag) String() string {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + ": " + h[name]
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (h headerFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return fmt.Errorf(`expected "Name: value", got %q`, value)
+	}
+	h[http.CanonicalHeaderKey(name)] = strings.TrimSpace(val)
+	return nil
+}
+
+// responseHeaders merges the default security headers with the configured
+// overrides, dropping headers whose value is empty.
+func responseHeaders(cfg *Config) map[string]string {
+	headers := map[string]string{}
+	if cfg.SecurityHeaders {
+		for name, value := range defaultSecurityHeaders {
+			headers[name] = value
+		}
+	}
+	for name, value := range cfg.Headers {
+		if value == "" {
+			delete(headers, name)
+		} else {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// headersMiddleware sets the configured response headers on every response,
+// API and static files alike.
+func headersMiddleware(cfg *Config) func(http.Handler) http.Handler {
+	headers := responseHeaders(cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for name, value := range headers {
+				w.Header().Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want map[string]string
+	}{
+		{nil, defaultSecurityHeaders},
+		{[]string{"-header", "X-Frame-Options: SAMEORIGIN", "-header", "Referrer-Policy:", "-header", "X-Robots-Tag: noindex"}, map[string]string{
+			"X-Content-Type-Options": "nosniff",
+			"X-Frame-Options":        "SAMEORIGIN",
+			"Referrer-Policy":        "",
+			"X-Robots-Tag":           "noindex",
+		}},
+		{[]string{"-security-headers=false"}, map[string]string{
+			"X-Content-Type-Options": "",
+			"X-Frame-Options":        "",
+			"Referrer-Policy":        "",
+		}},
+	} {
+		srv := newServer(newTestConfig(t, tc.args...), "", newTestSite(t, tc.args...), nil)
+
+		// API responses and the website get the same headers.
+		for _, path := range []string{"/data", "/"} {
+			rec := mustDo(t, srv.Handler, http.MethodGet, path, "", http.StatusOK)
+			for name, want := range tc.want {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%v: GET %s: %s = %q, want %q", tc.args, path, name, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestContentSecurityPolicyOnWebsiteOnly(t *testing.T) {
+	site := newTestSite(t)
+
+	if got := mustDo(t, site, http.MethodGet, "/", "", http.StatusOK).Header().Get("Content-Security-Policy"); got != defaultContentSecurityPolicy {
+		t.Errorf("website Content-Security-Policy = %q, want the default", got)
+	}
+	if got := mustDo(t, site, http.MethodGet, "/data", "", http.StatusOK).Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("API Content-Security-Policy = %q, want none", got)
+	}
+}
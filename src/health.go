@@ -0,0 +1,142 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// storeHealth is a snapshot of the store's degraded-mode state.
+type storeHealth struct {
+	ReadOnly       bool       `json:"readOnly"`
+	ReadOnlySince  *time.Time `json:"readOnlySince,omitempty"`
+	LastWriteError string     `json:"lastWriteError,omitempty"`
+	DiskFull       bool       `json:"diskFull,omitempty"`
+}
+
+// health returns the current degraded-mode state of the store.
+func (s *Store) health() storeHealth {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	h := storeHealth{ReadOnly: s.readOnly, LastWriteError: s.lastWriteError, DiskFull: s.readOnly && s.diskFull}
+	if s.readOnly {
+		since := s.readOnlySince
+		h.ReadOnlySince = &since
+	}
+	return h
+}
+
+// writeCheckTTL is how long the result of a write check is reused, so
+// frequent readiness probes don't hit the disk every time.
+const writeCheckTTL = 5 * time.Second
+
+// checkWritable verifies that a file can be created in the data file's
+// directory by writing and removing a small temporary file. The result is
+// cached for writeCheckTTL.
+func (s *Store) checkWritable() error {
+	s.writeCheckMu.Lock()
+	defer s.writeCheckMu.Unlock()
+
+	if time.Since(s.writeCheckAt) < writeCheckTTL {
+		return s.writeCheckErr
+	}
+	s.writeCheckAt = time.Now()
+	s.writeCheckErr = func() error {
+		f, err := os.CreateTemp(filepath.Dir(s.filepath), ".health-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString("ok"); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}()
+	return s.writeCheckErr
+}
+
+// healthHandler handles GET /health. It reports "degraded" instead of "ok"
+// while the store is read-only; reads keep working, so the status code stays
+// 200. A data directory that can't be written to is reported with
+// writable:false and 503, so readiness checks take the instance out of
+// rotation.
+func healthHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		status, code := "ok", http.StatusOK
+		if s.isReadOnly() {
+			status = "degraded"
+		}
+		response := map[string]interface{}{
+			"readOnly": status == "degraded",
+			"writable": true,
+		}
+		if err := s.checkWritable(); err != nil {
+			status, code = "degraded", http.StatusServiceUnavailable
+			response["writable"] = false
+			response["writeError"] = err.Error()
+		}
+		response["status"] = status
+		writeJSON(w, code, response)
+	}
+}
+
+// statusHandler handles GET /status, describing the server state in more
+// detail than /health.
+func statusHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /status: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		active := activeItems(data)
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"dataFile":      s.filepath,
+			"store":         s.health(),
+			"items":         len(active),
+			"archivedItems": len(catalogItems(data)) - len(active),
+			"pendingItems":  len(pendingItemIDs(data)),
+			"writeQueue":    s.writeQueueState(),
+			"nextDemoReset": s.nextDemoReset(),
+		})
+	}
+}
+
+// configHandler handles GET /config, the settings clients may want to adapt
+// to, such as the caching of GET /data and the rules writes are checked
+// against. Secrets such as the admin token are never part of it.
+func configHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		live := s.cfg.Live()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"cacheControl":    live.DataCacheControl,
+			"dataCacheTTL":    live.DataCacheTTL.String(),
+			"strictJSON":      s.cfg.StrictJSON,
+			"strictFields":    s.cfg.StrictFields,
+			"patchConflicts":  s.cfg.PatchConflicts,
+			"maxQuantity":     s.cfg.MaxQuantity,
+			"maxStringLength": s.cfg.MaxStringLength,
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDataCacheControl(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "no-store"},
+		{[]string{"-data-cache-control", "public, max-age=60"}, "public, max-age=60"},
+		{[]string{"-data-cache-control", ""}, ""},
+	} {
+		_, api := newTestAPI(t, tc.args...)
+
+		rec := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK)
+		if got := rec.Header().Get("Cache-Control"); got != tc.want {
+			t.Errorf("%v: Cache-Control = %q, want %q", tc.args, got, tc.want)
+		}
+
+		// A revalidation answered with 304 carries the same header.
+		req := httptest.NewRequest(http.MethodGet, "/data", nil)
+		req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+		notModified := httptest.NewRecorder()
+		api.ServeHTTP(notModified, req)
+		if notModified.Code != http.StatusNotModified {
+			t.Fatalf("%v: revalidation status %d, want 304", tc.args, notModified.Code)
+		}
+		if got := notModified.Header().Get("Cache-Control"); got != tc.want {
+			t.Errorf("%v: Cache-Control of the 304 = %q, want %q", tc.args, got, tc.want)
+		}
+
+		var config map[string]interface{}
+		decodeBody(t, mustDo(t, api, http.MethodGet, "/config", "", http.StatusOK), &config)
+		if config["cacheControl"] != tc.want {
+			t.Errorf("%v: GET /config cacheControl = %v, want %q", tc.args, config["cacheControl"], tc.want)
+		}
+	}
+}
+
+func TestHealthReportsUnwritableDataDir(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		breakDir func(dir string) error
+		fix      func(dir string) error
+	}{
+		{"read-only", func(dir string) error { return os.Chmod(dir, 0555) }, func(dir string) error { return os.Chmod(dir, 0755) }},
+		{"missing", os.RemoveAll, func(dir string) error { return os.Mkdir(dir, 0755) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.name == "read-only" && os.Geteuid() == 0 {
+				t.Skip("root can write to read-only directories")
+			}
+			s, api := newTestAPI(t)
+			dir := filepath.Dir(s.filepath)
+			if err := tc.breakDir(dir); err != nil {
+				t.Fatal(err)
+			}
+			defer tc.fix(dir)
+
+			var health map[string]interface{}
+			decodeBody(t, mustDo(t, api, http.MethodGet, "/health", "", http.StatusServiceUnavailable), &health)
+			if health["writable"] != false || health["status"] != "degraded" {
+				t.Errorf("health = %v, want writable false and degraded", health)
+			}
+
+			// The result is cached, so probes don't hit the disk every time.
+			if err := tc.fix(dir); err != nil {
+				t.Fatal(err)
+			}
+			mustDo(t, api, http.MethodGet, "/health", "", http.StatusServiceUnavailable)
+			s.writeCheckAt = time.Time{}
+			decodeBody(t, mustDo(t, api, http.MethodGet, "/health", "", http.StatusOK), &health)
+			if health["writable"] != true {
+				t.Errorf("health = %v after the directory is writable again, want writable", health)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// importTextHandler handles POST /import.txt. The text/plain body is a list
+// of item names, one per line, as pasted from a notes app. Blank lines and
+// lines starting with "#" are skipped. Each name is put on the pending list
+// with quantity 1, reusing the catalog item of the same name when there is
+// one and creating it otherwise.
+//
+// With ?mode=merge (the default) the names are added to the current pending
+// list; with ?mode=replace the pending list is cleared first. The catalog is
+// never cleared, so previously known items keep their images and tags.
+//
+// With ?preview=true nothing is changed; the response reports what the
+// import would do instead, including the lines that match an existing item
+// or are already pending (see importResult).
+func importTextHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "" {
+			if mediaType, _, _ := mime.ParseMediaType(ct); mediaType != "text/plain" {
+				writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "Content-Type must be text/plain")
+				return
+			}
+		}
+
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = "merge"
+		}
+		if mode != "merge" && mode != "replace" {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, `Parameter mode must be "merge" or "replace"`)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Could not read request body")
+			return
+		}
+		if s.cfg.DecodeUploads {
+			if body, err = decodeUpload(body); err != nil {
+				writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+				return
+			}
+		}
+		names, err := parseTextList(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Could not read the list: "+err.Error())
+			return
+		}
+
+		preview := r.URL.Query().Get("preview") == "true"
+		result := importResult{Conflicts: []importConflict{}}
+		err = s.update(r.Context(), func(data JSONData) error {
+			if mode == "replace" {
+				data[pendingKey] = []interface{}{}
+			}
+			for _, name := range names {
+				item, err := findItemByName(data, name)
+				if err != nil {
+					item = JSONData{"name": name}
+					normalizeItemName(s.cfg, item)
+					item["id"] = generateItemID(s.cfg, data, item["name"].(string))
+					s.applyItemDefaults(item)
+					applyDefaultTTL(s.cfg, item, time.Now())
+					setCatalog(data, append(catalogItems(data), item))
+					result.Created++
+				} else {
+					result.conflict(name, item, "matches an existing item, which is reused")
+				}
+				if addPending(data, item["id"].(string), 1) {
+					result.Imported++
+				} else {
+					result.conflict(name, item, "already on the pending list, skipped")
+				}
+			}
+			if preview {
+				return errUnchanged
+			}
+			return nil
+		})
+		if !preview {
+			writeItemResult(w, r, map[string]int{"imported": result.Imported}, err)
+			return
+		}
+		result.Preview = true
+		writeItemResult(w, r, result, err)
+	}
+}
+
+// importConflict is a line of an import that matched existing data.
+type importConflict struct {
+	Name   string `json:"name"`
+	ItemID string `json:"itemId"`
+	Reason string `json:"reason"`
+}
+
+// importResult is the ?preview=true report of POST /import.txt: how many
+// entries would be put on the pending list, how many catalog items created,
+// and which lines matched existing data.
+type importResult struct {
+	Preview   bool             `json:"preview"`
+	Imported  int              `json:"imported"`
+	Created   int              `json:"created"`
+	Conflicts []importConflict `json:"conflicts"`
+}
+
+func (res *importResult) conflict(name string, item JSONData, reason string) {
+	id, _ := item["id"].(string)
+	res.Conflicts = append(res.Conflicts, importConflict{Name: name, ItemID: id, Reason: reason})
+}
+
+// parseTextList returns the trimmed item names of a plain text list,
+// skipping blank lines and "#" comments. The body is already in memory, so
+// lines may be as long as the body itself rather than bufio's default token
+// limit.
+func parseTextList(body []byte) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
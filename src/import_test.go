@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTextList(t *testing.T) {
+	long := strings.Repeat("x", 100<<10)
+	for _, body := range []string{"", "a", "milk\n\n# comment\n  eggs  \n", "milk\n" + long + "\neggs"} {
+		names, err := parseTextList([]byte(body))
+		if err != nil {
+			t.Fatalf("parseTextList(%.20q): %v", body, err)
+		}
+		var want []string
+		for _, line := range strings.Split(body, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				want = append(want, line)
+			}
+		}
+		if strings.Join(names, "\n") != strings.Join(want, "\n") {
+			t.Errorf("parseTextList(%.20q) = %d names, want %d", body, len(names), len(want))
+		}
+	}
+}
+
+func TestImportTextLongLine(t *testing.T) {
+	_, api := newTestAPI(t)
+	req := httptest.NewRequest(http.MethodPost, "/import.txt", strings.NewReader("milk\n"+strings.Repeat("x", 100<<10)+"\neggs\n"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"imported":3`) {
+		t.Errorf("import: status %d, body %s; want all three lines imported", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// errURLNotAllowed is returned for import URLs the server refuses to fetch:
+// another scheme than http(s), a host outside -import-url-hosts, or, without
+// an allowlist, a host resolving to a non-public address.
+var errURLNotAllowed = errors.New("URL not allowed")
+
+// importURLResult is the summary returned by POST /import-url.
+type importURLResult struct {
+	Mode string `json:"mode"`
+	// Backup is the file holding the document as it was before the import.
+	Backup string `json:"backup"`
+	// Added and Skipped count the catalog items of the fetched document
+	// that were added, and those skipped because their id already exists.
+	Added   int `json:"added"`
+	Skipped int `json:"skipped"`
+	// Pending counts the entries added to the pending list.
+	Pending int `json:"pending"`
+}
+
+// importURLHandler handles POST /import-url with a body of the form
+// {"url": "https://..."}. The server fetches the document at the URL (a
+// shopping list as served by GET /data, e.g. a shared gist), validates it and
+// applies it according to ?mode=: with "merge" (the default) the catalog
+// items with new ids and their pending entries are added to the list, with
+// "replace" the document replaces the list altogether. The previous document
+// is saved next to the data file first, see importBackupPath.
+func importURLHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = "merge"
+		}
+		if mode != "merge" && mode != "replace" {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, `Parameter mode must be "merge" or "replace"`)
+			return
+		}
+
+		var body struct {
+			URL string `json:"url"`
+		}
+		if err := decodeJSON(s.cfg, r.Body, &body); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+		if body.URL == "" {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Field url is required")
+			return
+		}
+
+		content, err := fetchImportURL(s.cfg, body.URL)
+		if errors.Is(err, errURLNotAllowed) {
+			writeError(w, http.StatusBadRequest, codeURLNotAllowed, err.Error())
+			return
+		} else if err != nil {
+			writeError(w, http.StatusBadGateway, codeFetchFailed, "Could not fetch "+body.URL+": "+err.Error())
+			return
+		}
+
+		var doc JSONData
+		if content, err = documentRoot(s.cfg, content); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, fmt.Sprintf("The document at %s is invalid: %v", body.URL, err))
+			return
+		}
+		if s.cfg.RejectDuplicateKeys {
+			err = checkDuplicateKeys(content)
+		}
+		if err == nil {
+			err = json.Unmarshal(content, &doc)
+		}
+		if err != nil || doc == nil {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, fmt.Sprintf("The document at %s is not a JSON object", body.URL))
+			return
+		}
+		for _, item := range catalogItems(doc) {
+			normalizeItemName(s.cfg, item)
+		}
+		dropBlankNames(s.cfg, doc)
+		if err := validateDocument(s.cfg, doc); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
+			return
+		}
+
+		result := importURLResult{Mode: mode, Backup: importBackupPath(s)}
+		err = s.update(r.Context(), func(data JSONData) error {
+			if err := s.backupBeforeImport(data); err != nil {
+				return err
+			}
+			if mode == "replace" {
+				for key := range data {
+					delete(data, key)
+				}
+				for key, value := range doc {
+					data[key] = value
+				}
+				result.Added = len(catalogItems(doc))
+				result.Pending = len(pendingItemIDs(doc))
+				return nil
+			}
+			result.Added, result.Skipped, result.Pending = mergeDocument(data, doc)
+			return nil
+		})
+		writeItemResult(w, r, result, err)
+	}
+}
+
+// mergeDocument adds the catalog items of doc whose id is not in data yet,
+// and puts those of its pending entries that aren't pending in data on the
+// pending list. Items already known keep their current fields. Pending
+// entries without a positive quantity are added with 1.
+func mergeDocument(data, doc JSONData) (added, skipped, pending int) {
+	items := catalogItems(data)
+	for _, item := range catalogItems(doc) {
+		id, _ := item["id"].(string)
+		if _, err := findItem(data, id); err == nil {
+			skipped++
+			continue
+		}
+		items = append(items, item)
+		added++
+	}
+	setCatalog(data, items)
+
+	raw, _ := doc[pendingKey].([]interface{})
+	for _, entry := range raw {
+		ref, _ := entry.(map[string]interface{})
+		itemID, _ := ref["itemId"].(string)
+		quantity, ok := ref["quantity"].(float64)
+		if !ok || quantity <= 0 {
+			quantity = 1
+		}
+		if _, err := findItem(data, itemID); err == nil && addPending(data, itemID, quantity) {
+			pending++
+		}
+	}
+	return added, skipped, pending
+}
+
+// importBackupPath is the file the document is saved to before an import
+// from a URL changes it. Only the latest backup is kept.
+func importBackupPath(s *Store) string {
+	return s.filepath + ".before-import"
+}
+
+// backupBeforeImport saves data to importBackupPath. The import is aborted
+// when the backup can't be written.
+func (s *Store) backupBeforeImport(data JSONData) error {
+	if s.mem != nil {
+		return nil
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling import backup: %w", err)
+	}
+	if err := writeFileAtomic(importBackupPath(s), content, 0644); err != nil {
+		return fmt.Errorf("error writing import backup: %w", err)
+	}
+	return nil
+}
+
+// fetchImportURL downloads the document at raw, within -import-url-timeout
+// and -import-url-max-bytes. Redirects are checked like the URL itself.
+func fetchImportURL(cfg *Config, raw string) ([]byte, error) {
+	live := cfg.Live()
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errURLNotAllowed, err)
+	}
+	if err := checkImportURL(live, u); err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: live.ImportURLTimeout}
+	if len(live.ImportURLHosts) == 0 {
+		// Checked on the resolved address of every connection, so a
+		// public name pointing at an internal address is refused too.
+		dialer.Control = publicAddressOnly
+	}
+	client := &http.Client{
+		Timeout: live.ImportURLTimeout,
+		// No proxy from the environment, it would be dialed instead of
+		// the checked host. The transport serves a single fetch, so it
+		// keeps no idle connections around afterwards.
+		Transport: &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return checkImportURL(live, req.URL)
+		},
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > live.ImportURLMaxBytes {
+		return nil, fmt.Errorf("document is larger than %d bytes", live.ImportURLMaxBytes)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, live.ImportURLMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > live.ImportURLMaxBytes {
+		return nil, fmt.Errorf("document is larger than %d bytes", live.ImportURLMaxBytes)
+	}
+	if cfg.DecodeUploads {
+		return decodeUpload(content)
+	}
+	return content, nil
+}
+
+// checkImportURL enforces the scheme and the -import-url-hosts allowlist.
+func checkImportURL(live *Reloadable, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: only http and https URLs can be imported", errURLNotAllowed)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: the URL has no host", errURLNotAllowed)
+	}
+	if len(live.ImportURLHosts) > 0 && !slices.Contains(live.ImportURLHosts, strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("%w: host %s is not in the allowed import hosts", errURLNotAllowed, u.Hostname())
+	}
+	return nil
+}
+
+// publicAddressOnly is a net.Dialer Control function refusing connections
+// to loopback, private, link-local and other non-public addresses.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s is not a public address", errURLNotAllowed, host)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+)
+
+func TestMergeDocumentQuantities(t *testing.T) {
+	var doc JSONData
+	if err := json.Unmarshal([]byte(`{
+		"catalog": [{"id": "a"}, {"id": "b"}, {"id": "c"}],
+		"pendingList": [{"itemId": "a", "quantity": 0.25}, {"itemId": "b", "quantity": 0}, {"itemId": "c"}]
+	}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	data := JSONData{catalogKey: []interface{}{}, pendingKey: []interface{}{}}
+	if added, _, pending := mergeDocument(data, doc); added != 3 || pending != 3 {
+		t.Fatalf("added %d items and %d pending entries, want 3 and 3", added, pending)
+	}
+	want := map[string]interface{}{"a": 0.25, "b": 1.0, "c": 1.0}
+	if got := pendingQuantities(data); !maps.Equal(got, want) {
+		t.Errorf("quantities = %v, want %v", got, want)
+	}
+}
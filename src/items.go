@@ -0,0 +1,551 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Top-level keys of the document managed by the web client.
+const (
+	// catalogKey holds the item blueprints ({ id, name, imageUrl, ... }).
+	catalogKey = "catalog"
+	// pendingKey holds what needs to be bought ({ itemId, quantity }).
+	pendingKey = "pendingList"
+)
+
+// errItemNotFound is returned when an item id does not exist in the catalog.
+var errItemNotFound = errors.New("item not found")
+
+// errItemExists is returned when creating an item with an id already in use.
+var errItemExists = errors.New("an item with this id already exists")
+
+// validationError reports a request that is well-formed JSON but cannot be
+// applied to the data. Handlers answer it with 422 Unprocessable Entity.
+type validationError struct {
+	msg string
+}
+
+func (e *validationError) Error() string { return e.msg }
+
+// catalogItems returns the catalog entries that are JSON objects. Entries of
+// any other shape are skipped rather than treated as an error.
+func catalogItems(data JSONData) []JSONData {
+	raw, _ := data[catalogKey].([]interface{})
+	items := make([]JSONData, 0, len(raw))
+	for _, entry := range raw {
+		if item, ok := entry.(map[string]interface{}); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// findItem looks up a catalog item by its id.
+func findItem(data JSONData, id string) (JSONData, error) {
+	for _, item := range catalogItems(data) {
+		if itemID, _ := item["id"].(string); itemID == id {
+			return item, nil
+		}
+	}
+	return nil, errItemNotFound
+}
+
+// setCatalog replaces the catalog with the given items.
+func setCatalog(data JSONData, items []JSONData) {
+	raw := make([]interface{}, len(items))
+	for i, item := range items {
+		raw[i] = map[string]interface{}(item)
+	}
+	data[catalogKey] = raw
+}
+
+// newItemID generates an id in the same format the web client uses
+// ("id-<unix millis>-<random>"), retrying until it is unused in the catalog
+// and not among the reserved ids handed out earlier in the same operation.
+func newItemID(data JSONData, reserved ...string) string {
+	for {
+		id := fmt.Sprintf("id-%d-%d", time.Now().UnixMilli(), rand.Intn(9999))
+		if _, err := findItem(data, id); errors.Is(err, errItemNotFound) && !slices.Contains(reserved, id) {
+			return id
+		}
+	}
+}
+
+// newUUIDItemID generates a random (version 4) UUID unused in the catalog
+// and not reserved.
+func newUUIDItemID(data JSONData, reserved ...string) string {
+	for {
+		var b [16]byte
+		crand.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		id := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		if _, err := findItem(data, id); errors.Is(err, errItemNotFound) && !slices.Contains(reserved, id) {
+			return id
+		}
+	}
+}
+
+// generateItemID creates the id for a new item according to -item-ids,
+// avoiding ids reserved earlier in the same operation.
+func generateItemID(cfg *Config, data JSONData, name string, reserved ...string) string {
+	switch cfg.ItemIDs {
+	case "slug":
+		return slugItemID(data, name, reserved...)
+	case "uuid":
+		return newUUIDItemID(data, reserved...)
+	}
+	return newItemID(data, reserved...)
+}
+
+// findItemByName looks up a catalog item by name, ignoring case, accents and
+// surrounding whitespace.
+func findItemByName(data JSONData, name string) (JSONData, error) {
+	key := foldAccents(strings.TrimSpace(name))
+	for _, item := range catalogItems(data) {
+		if itemName, _ := item["name"].(string); foldAccents(strings.TrimSpace(itemName)) == key {
+			return item, nil
+		}
+	}
+	return nil, errItemNotFound
+}
+
+// addPending puts an item on the pending list with the given quantity,
+// unless it is already there. It reports whether the item was added.
+func addPending(data JSONData, itemID string, quantity float64) bool {
+	if pendingItemIDs(data)[itemID] {
+		return false
+	}
+	pending, _ := data[pendingKey].([]interface{})
+	data[pendingKey] = append(pending, map[string]interface{}{"itemId": itemID, "quantity": quantity})
+	return true
+}
+
+// copyItem returns a deep copy of an item so the copy can be modified
+// without affecting the original.
+func copyItem(item JSONData) JSONData {
+	return copyJSON(map[string]interface{}(item)).(map[string]interface{})
+}
+
+// copyDocument returns a deep copy of the whole document.
+func copyDocument(data JSONData) JSONData {
+	return copyJSON(map[string]interface{}(data)).(map[string]interface{})
+}
+
+// copyJSON deep-copies a value decoded from JSON.
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = copyJSON(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = copyJSON(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// replacePendingRefs rewrites the pending list so entries pointing at oldID
+// point at each of newIDs instead, keeping their quantity. With no newIDs the
+// entries are simply dropped.
+func replacePendingRefs(data JSONData, oldID string, newIDs []string) {
+	raw, ok := data[pendingKey].([]interface{})
+	if !ok {
+		return
+	}
+	pending := make([]interface{}, 0, len(raw))
+	for _, entry := range raw {
+		ref, ok := entry.(map[string]interface{})
+		if itemID, _ := ref["itemId"].(string); !ok || itemID != oldID {
+			pending = append(pending, entry)
+			continue
+		}
+		for _, id := range newIDs {
+			replacement := copyJSON(ref).(map[string]interface{})
+			replacement["itemId"] = id
+			pending = append(pending, replacement)
+		}
+	}
+	data[pendingKey] = pending
+}
+
+// normalizeTag trims and lowercases a tag so "Organic " and "organic" match.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// itemTags returns the normalized, deduplicated tags of an item in their
+// stored order.
+func itemTags(item JSONData) []string {
+	raw, _ := item["tags"].([]interface{})
+	tags := make([]string, 0, len(raw))
+	seen := make(map[string]bool, len(raw))
+	for _, entry := range raw {
+		str, ok := entry.(string)
+		if !ok {
+			continue
+		}
+		tag := normalizeTag(str)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// setItemTags stores tags on the item as a JSON array.
+func setItemTags(item JSONData, tags []string) {
+	raw := make([]interface{}, len(tags))
+	for i, tag := range tags {
+		raw[i] = tag
+	}
+	item["tags"] = raw
+}
+
+// hasTag reports whether the item carries the given (normalized) tag.
+func hasTag(item JSONData, tag string) bool {
+	for _, t := range itemTags(item) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+// The body is serialized up front so Content-Length and an X-Content-SHA256
+// checksum can be sent, letting clients show download progress and verify
+// the body, and proxies buffer efficiently. Should a compressing middleware
+// ever be added it must drop both headers, since they then no longer match
+// what goes over the wire.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	start := time.Now()
+	body, err := json.Marshal(v)
+	timingsOf(w).since("serialize", start)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+		return
+	}
+	body = append(body, '\n')
+
+	setChecksum(w, sha256.Sum256(body))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// listItemsHandler handles GET /data/items, returning the catalog items.
+//
+// Archived items are left out unless ?includeArchived=true.
+//
+// ?tag= restricts the result to items carrying that tag.
+//
+// ?sort=route orders the items by the aisle of their category (see
+// /settings/sections).
+//
+// ?unit=metric-base converts item quantities to grams or millilitres (see
+// toMetricBase).
+//
+// ?select=id,name,subItems{name} returns only the selected, possibly
+// nested, fields.
+//
+// The ETag covers only the returned items. With ?stream=true the array is
+// streamed item by item instead, without an ETag.
+func listItemsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /data/items: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		items := activeItems(data)
+		if r.URL.Query().Get("includeArchived") == "true" {
+			items = catalogItems(data)
+		}
+		if tag := normalizeTag(r.URL.Query().Get("tag")); tag != "" {
+			filtered := make([]JSONData, 0, len(items))
+			for _, item := range items {
+				if hasTag(item, tag) {
+					filtered = append(filtered, item)
+				}
+			}
+			items = filtered
+		}
+
+		if r.URL.Query().Get("sort") == "route" {
+			sections, err := s.loadSections()
+			if err != nil {
+				log.Printf("Error in GET /data/items: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			sortByRoute(s.cfg, items, sections)
+		}
+
+		switch r.URL.Query().Get("unit") {
+		case "":
+		case "metric-base":
+			for _, item := range items {
+				toMetricBase(item)
+			}
+		default:
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, `Parameter unit must be "metric-base"`)
+			return
+		}
+
+		if v := r.URL.Query().Get("select"); v != "" {
+			sel, err := parseSelection(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Invalid select parameter: "+err.Error())
+				return
+			}
+			for i, item := range items {
+				items[i] = sel.project(map[string]interface{}(item)).(map[string]interface{})
+			}
+		}
+
+		if r.URL.Query().Get("stream") == "true" {
+			streamJSONArray(w, r, items)
+			return
+		}
+		writeJSONWithETag(s.cfg, w, r, items)
+	}
+}
+
+// addItemTagHandler handles POST /data/items/{id}/tags with a body of the
+// form {"tag": "organic"}, adding the tag to the item unless already present.
+func addItemTagHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var body struct {
+			Tag string `json:"tag"`
+		}
+		if err := decodeJSON(s.cfg, r.Body, &body); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+		tag := normalizeTag(body.Tag)
+		if tag == "" {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Tag must not be empty")
+			return
+		}
+
+		var updated JSONData
+		err := s.update(r.Context(), func(data JSONData) error {
+			item, err := findItem(data, mux.Vars(r)["id"])
+			if err != nil {
+				return err
+			}
+			tags := itemTags(item)
+			if !hasTag(item, tag) {
+				tags = append(tags, tag)
+			}
+			setItemTags(item, tags)
+			updated = item
+			return nil
+		})
+		writeItemResult(w, r, updated, err)
+	}
+}
+
+// removeItemTagHandler handles DELETE /data/items/{id}/tags/{tag}. Removing a
+// tag the item does not carry is not an error.
+func removeItemTagHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		vars := mux.Vars(r)
+		tag := normalizeTag(vars["tag"])
+
+		var updated JSONData
+		err := s.update(r.Context(), func(data JSONData) error {
+			item, err := findItem(data, vars["id"])
+			if err != nil {
+				return err
+			}
+			tags := itemTags(item)
+			kept := tags[:0]
+			for _, t := range tags {
+				if t != tag {
+					kept = append(kept, t)
+				}
+			}
+			setItemTags(item, kept)
+			updated = item
+			return nil
+		})
+		writeItemResult(w, r, updated, err)
+	}
+}
+
+// createItemHandler handles POST /data/items, adding a single item to the
+// catalog. Clients may send only a name: the id is then generated either as
+// a slug of the name or in the web client's random format, per -item-ids.
+// A client-supplied id is kept as long as it is not already in use.
+func createItemHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		item, err := decodeItem(s.cfg, r.Body)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		normalizeItemName(s.cfg, item)
+		name, _ := item["name"].(string)
+		if strings.TrimSpace(name) == "" {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, "Item name is required")
+			return
+		}
+		if err := validateItem(s.cfg, item); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
+			return
+		}
+
+		err = s.update(r.Context(), func(data JSONData) error {
+			if id, _ := item["id"].(string); id != "" {
+				if _, err := findItem(data, id); err == nil {
+					return errItemExists
+				}
+			} else {
+				item["id"] = generateItemID(s.cfg, data, name)
+			}
+			s.applyItemDefaults(item)
+			applyDefaultTTL(s.cfg, item, time.Now())
+			setCatalog(data, append(catalogItems(data), item))
+			return nil
+		})
+		if err != nil {
+			writeItemResult(w, r, nil, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, item)
+	}
+}
+
+// deleteItemsHandler handles DELETE /data/items with a body of the form
+// {"ids": ["...", ...]}, removing all listed items (and their pending list
+// entries) in a single write. The remaining items keep their order. The
+// response reports how many items were removed and which ids were unknown.
+// With ?dryRun=true nothing is deleted; the response lists the items that
+// would have been removed instead.
+func deleteItemsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		if err := decodeJSON(s.cfg, r.Body, &body); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+
+		dryRun := r.URL.Query().Get("dryRun") == "true"
+		var result struct {
+			Removed  int      `json:"removed"`
+			NotFound []string `json:"notFound"`
+		}
+		preview := []JSONData{}
+		err := s.update(r.Context(), func(data JSONData) error {
+			removed := removeItems(data, body.IDs)
+			result.Removed = len(removed)
+			result.NotFound = []string{}
+			for _, id := range body.IDs {
+				if item, ok := removed[id]; ok {
+					preview = append(preview, item)
+				} else {
+					result.NotFound = append(result.NotFound, id)
+				}
+			}
+			if dryRun {
+				return errUnchanged
+			}
+			return nil
+		})
+		if dryRun && err == nil {
+			writeJSON(w, http.StatusOK, map[string]interface{}{
+				"dryRun":   true,
+				"removed":  result.Removed,
+				"notFound": result.NotFound,
+				"items":    preview,
+			})
+			return
+		}
+		writeItemResult(w, r, result, err)
+	}
+}
+
+// removeItems deletes the catalog items with the given ids, along with
+// their pending list entries, and returns the removed items by id.
+func removeItems(data JSONData, ids []string) map[string]JSONData {
+	removed := make(map[string]JSONData)
+	items := catalogItems(data)
+	kept := make([]JSONData, 0, len(items))
+	for _, item := range items {
+		itemID, _ := item["id"].(string)
+		if slices.Contains(ids, itemID) {
+			removed[itemID] = item
+			replacePendingRefs(data, itemID, nil)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	setCatalog(data, kept)
+	return removed
+}
+
+// writeItemResult writes the outcome of an item mutation: the result on
+// success, 404 for unknown ids, 409 for id clashes, 422 for validation errors,
+// 503 while the store is read-only or overloaded, 507 when the disk is full
+// and 500 for other storage failures.
+func writeItemResult(w http.ResponseWriter, r *http.Request, result interface{}, err error) {
+	if err != nil {
+		writeStoreError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -1,107 +1,110 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"sync"
-
-	"github.com/gorilla/handlers"
-	"github.com/gorilla/mux"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
-// The path where the JSON data will be stored persistently.
-const dataFilePath = "data.json"
-
-// JSONData is a type alias for a generic JSON object structure.
-type JSONData map[string]interface{}
-
-// Store holds the application state, including the file path and a mutex
-// for concurrent access control to the file.
-type Store struct {
-	filepath string
-	// RWMutex allows many readers or one writer at a time.
-	mu sync.RWMutex
-}
-
-// NewStore initializes a new Store and ensures the data file exists.
-func NewStore(path string) *Store {
-	s := &Store{filepath: path}
-	// Attempt to create the file if it doesn't exist, initializing it with an empty JSON object.
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		log.Printf("Data file %s not found, creating a new empty one.", path)
-		if err := s.saveDataFile(JSONData{}); err != nil {
-			log.Fatalf("Failed to initialize data file: %v", err)
+// getDataHandler handles GET /data requests to fetch the JSON content. The
+// X-Data-Version header carries the changes feed cursor the content matches.
+// Polling clients pass it back as ?sinceVersion=N to receive only what
+// changed since (see dataDelta). The delta is keyed by id, so a change in
+// the order of the catalog alone is not part of it.
+//
+// With ?store=, ?aisle= or ?checked= the response is instead the array of
+// pending entries whose item is in that store or aisle, see shopFilter,
+// optionally in aisle order with ?sort=aisle.
+//
+// Both kinds of response carry the configured Cache-Control. The full
+// document also has an ETag, so a CDN or browser whose copy went stale
+// revalidates it with If-None-Match and gets a 304 while it is unchanged.
+func getDataHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
 		}
-	}
-	return s
-}
-
-// readDataFile reads the JSON data from the file, locking the store for reading.
-func (s *Store) readDataFile() (JSONData, error) {
-	s.mu.RLock()         // Acquire read lock
-	defer s.mu.RUnlock() // Release read lock when function returns
-
-	content, err := os.ReadFile(s.filepath)
-	if err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
-	}
-
-	// Handle empty file case
-	if len(content) == 0 {
-		return JSONData{}, nil
-	}
-
-	var data JSONData
-	if err := json.Unmarshal(content, &data); err != nil {
-		return nil, fmt.Errorf("error unmarshaling JSON: %w", err)
-	}
-	return data, nil
-}
 
-// saveDataFile writes the JSON data to the file, locking the store for writing.
-// This function overwrites the entire file content.
-func (s *Store) saveDataFile(data JSONData) error {
-	s.mu.Lock()         // Acquire write lock
-	defer s.mu.Unlock() // Release write lock when function returns
-
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshaling JSON: %w", err)
-	}
-
-	// Write the data to the file, overwriting existing content.
-	if err := os.WriteFile(s.filepath, jsonData, 0644); err != nil {
-		return fmt.Errorf("error writing to file: %w", err)
-	}
+		if v := r.URL.Query().Get("sinceVersion"); v != "" {
+			version, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || version < 0 {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Parameter sinceVersion must be a non-negative integer")
+				return
+			}
+			delta, err := s.deltaSince(r.Context(), version)
+			if err != nil {
+				log.Printf("Error in GET /data: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			setDataCacheControl(s.cfg, w)
+			writeJSON(w, http.StatusOK, delta)
+			return
+		}
 
-	log.Printf("Successfully saved data to %s", s.filepath)
-	return nil
-}
+		filter, filtered, err := parseShopFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidParameter, "Invalid filter: "+err.Error())
+			return
+		}
+		if filtered {
+			data, err := s.readDataFile(r.Context())
+			if err != nil {
+				log.Printf("Error in GET /data: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			setDataCacheControl(s.cfg, w)
+			writeJSONWithETag(s.cfg, w, r, filterPending(s.cfg, data, filter))
+			return
+		}
 
-// getDataHandler handles GET /data requests to fetch the JSON content.
-func getDataHandler(s *Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		if s.cfg.Live().DataCacheTTL > 0 {
+			cached, hit, err := s.cachedDataResponse(r.Context())
+			if err != nil {
+				log.Printf("Error in GET /data: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			w.Header().Set("X-Data-Version", strconv.FormatInt(cached.version, 10))
+			if hit {
+				w.Header().Set("X-Data-Cache", "hit")
+			} else {
+				w.Header().Set("X-Data-Cache", "miss")
+			}
+			setDataCacheControl(s.cfg, w)
+			cached.body.serve(w, r)
 			return
 		}
 
-		data, err := s.readDataFile()
+		data, version, err := s.readVersioned(r.Context())
 		if err != nil {
 			log.Printf("Error in GET /data: %v", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(data); err != nil {
-			log.Printf("Error encoding response: %v", err)
-			// Note: Cannot send another header/status after writing the body
-		}
+		w.Header().Set("X-Data-Version", strconv.FormatInt(version, 10))
+		setDataCacheControl(s.cfg, w)
+		writeJSONWithETag(s.cfg, w, r, data)
+	}
+}
+
+// setDataCacheControl sends the Cache-Control configured for GET /data,
+// unless it was configured empty.
+func setDataCacheControl(cfg *Config, w http.ResponseWriter) {
+	if cacheControl := cfg.Live().DataCacheControl; cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
 	}
 }
 
@@ -109,66 +112,182 @@ func getDataHandler(s *Store) http.HandlerFunc {
 func updateDataHandler(s *Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost && r.Method != http.MethodPut {
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
 			return
 		}
 
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "Could not read request body", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Could not read request body")
+			return
+		}
+
+		if s.cfg.DecodeUploads {
+			if body, err = decodeUpload(body); err != nil {
+				writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+				return
+			}
+		}
+
+		newData, err := parseDocument(s.cfg, body)
+		if err != nil {
+			writeDecodeError(w, err)
 			return
 		}
 
-		var newData JSONData
-		if err := json.Unmarshal(body, &newData); err != nil {
-			http.Error(w, "Invalid JSON format in request body", http.StatusBadRequest)
+		blankNamesDropped := dropBlankNames(s.cfg, newData)
+
+		// By default an invalid item rejects the whole write; with
+		// ?lenient=true invalid items are dropped and reported instead.
+		lenient := r.URL.Query().Get("lenient") == "true"
+		var skipped []skippedItem
+		if lenient {
+			skipped = dropInvalidItems(s.cfg, newData)
+		} else if err := validateDocument(s.cfg, newData); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
 			return
 		}
 
 		// Save the new data, overwriting the old content.
-		if err := s.saveDataFile(newData); err != nil {
-			log.Printf("Error in %s /data: %v", r.Method, err)
-			http.Error(w, "Internal Server Error: Failed to save data", http.StatusInternalServerError)
+		oldData, err := s.replace(r.Context(), newData)
+		if err != nil {
+			writeStoreError(w, r, err)
 			return
 		}
 
+		// Items ticked off the pending list count towards the purchase
+		// history. The list itself is already saved, so a failure here is
+		// only logged.
+		if err := s.purchases.record(boughtItems(oldData, newData)); err != nil {
+			log.Printf("Error recording purchases: %v", err)
+		}
+
 		// Success response
 		status := http.StatusOK
 		if r.Method == http.MethodPost {
 			status = http.StatusCreated // Use 201 for POST (new resource state created)
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(status)
-		fmt.Fprintf(w, `{"message": "Data successfully stored/updated", "status": %d}`, status)
+		response := map[string]interface{}{
+			"message": "Data successfully stored/updated",
+			"status":  status,
+		}
+		// With ?withDiff=true the response tells what the overwrite changed,
+		// compared against the state it replaced under the same write lock.
+		if r.URL.Query().Get("withDiff") == "true" {
+			response["diff"] = diffDocuments(oldData, newData)
+		}
+		if lenient {
+			response["skipped"] = skipped
+		}
+		if s.cfg.BlankNames == "drop" {
+			response["blankNamesDropped"] = blankNamesDropped
+		}
+		writeJSON(w, status, response)
 	}
 }
 
 func main() {
-	// 1. Initialize the Store
-	store := NewStore(dataFilePath)
+	cfg := parseConfig()
+	if cfg.Debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+		slog.Warn("Debug mode is on, responses can be delayed with ?delay=<ms>; don't use it in production",
+			"injectDelay", cfg.Live().InjectDelay)
+	}
 
-	router := mux.NewRouter()
+	// 1. Initialize the Store
+	store := NewStore(cfg)
+	if cfg.RepairOnStart {
+		if err := repairOnStart(store); err != nil {
+			log.Fatalf("Failed to repair data file: %v", err)
+		}
+	}
+	if cfg.ExpiryInterval > 0 {
+		go store.expireItems()
+	}
+	if cfg.DemoReset > 0 {
+		log.Printf("Demo mode: the list is reset to %s every %s", cfg.DemoTemplate, cfg.DemoReset)
+		go store.resetDemo()
+	}
 
-	router.HandleFunc("/data", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodGet:
-			getDataHandler(store)(w, r)
-		case http.MethodPost, http.MethodPut:
-			updateDataHandler(store)(w, r)
-		default:
-			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	// 2. Assemble the handlers
+	api := NewRouter(cfg, store)
+	static := staticHandler(cfg, "website")
+	var quota *RequestQuota
+	if cfg.quotaEnabled {
+		var err error
+		if quota, err = NewRequestQuota(cfg, cfg.QuotaFile); err != nil {
+			log.Fatalf("Failed to load request quota: %v", err)
 		}
-	})
+		go quota.flushEvery(10 * time.Second)
+	}
+
+	var servers []*http.Server
+	if cfg.APIPort != "" && cfg.StaticPort != "" {
+		// Separate listeners, so the API can be firewalled independently.
+		servers = append(servers,
+			newServer(cfg, ":"+cfg.APIPort, withMethodOverride(cfg, withCORS(api)), quota),
+			newServer(cfg, ":"+cfg.StaticPort, requestLogMiddleware(cfg)(static), quota),
+		)
+	} else {
+		api.PathPrefix("/").Handler(static)
+		servers = append(servers, newServer(cfg, ":"+cfg.Port, withMethodOverride(cfg, withCORS(api)), quota))
+	}
+
+	// 3. Start the servers and shut them down gracefully on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			log.Printf("Starting server on %s", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("server on %s: %w", srv.Addr, err)
+			}
+		}(srv)
+	}
 
-	router.PathPrefix("/").Handler(http.FileServer(http.Dir("website")))
+	select {
+	case err := <-errs:
+		log.Printf("Shutting down: %v", err)
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+	}
 
-	headers := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
-	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
-	origins := handlers.AllowedOrigins([]string{"*"})
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down server on %s: %v", srv.Addr, err)
+		}
+	}
+	if quota != nil {
+		if err := quota.flush(); err != nil {
+			log.Printf("Error saving request quota: %v", err)
+		}
+	}
+}
 
-	// 3. Start the server
-	port := "80"
-	log.Printf("Starting API server on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, handlers.CORS(headers, methods, origins)(router)))
+// newServer creates an HTTP server for addr. With -h2c it also accepts
+// cleartext HTTP/2, for deployments where a proxy terminates TLS upstream and
+// forwards HTTP/2 without encryption. Requests count towards the daily quota
+// when one is set, and in -debug mode responses can be delayed. The
+// configured response headers are set outermost, so they also apply to the
+// requests turned away before reaching h, such as by the quota.
+func newServer(cfg *Config, addr string, h http.Handler, quota *RequestQuota) *http.Server {
+	if quota != nil {
+		h = quotaMiddleware(quota)(h)
+	}
+	if cfg.Debug {
+		h = delayMiddleware(cfg)(h)
+	}
+	h = headersMiddleware(cfg)(h)
+	srv := &http.Server{Addr: addr, Handler: h}
+	if cfg.H2C {
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
 }
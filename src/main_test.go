@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestConfig loads a configuration from args, with every file the server
+// keeps placed in a fresh temporary directory.
+func newTestConfig(t *testing.T, args ...string) *Config {
+	t.Helper()
+	dir := t.TempDir()
+	base := []string{
+		"-data-file", filepath.Join(dir, "data.json"),
+		"-purchases-file", filepath.Join(dir, "purchases.json"),
+		"-changes-file", filepath.Join(dir, "changes.json"),
+		"-sections-file", filepath.Join(dir, "sections.json"),
+		"-preferences-file", filepath.Join(dir, "preferences.json"),
+		"-quota-file", filepath.Join(dir, "quota.json"),
+		"-fsync=false",
+	}
+	fs := flag.NewFlagSet(t.Name(), flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg, err := loadConfig(fs, append(base, args...))
+	if err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+	return cfg
+}
+
+// newTestStore returns a store with an empty list, configured from args.
+func newTestStore(t *testing.T, args ...string) *Store {
+	t.Helper()
+	return NewStore(newTestConfig(t, args...))
+}
+
+// newTestAPI returns the API router of a store with an empty list,
+// configured from args.
+func newTestAPI(t *testing.T, args ...string) (*Store, http.Handler) {
+	t.Helper()
+	s := newTestStore(t, args...)
+	return s, NewRouter(s.cfg, s)
+}
+
+// do sends a request with a JSON body, or none when body is empty, and
+// returns the recorded response.
+func do(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// mustDo is do, failing the test unless the response has status want.
+func mustDo(t *testing.T, h http.Handler, method, path, body string, want int) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := do(h, method, path, body)
+	if rec.Code != want {
+		t.Fatalf("%s %s: status %d, want %d; body: %s", method, path, rec.Code, want, rec.Body)
+	}
+	return rec
+}
+
+// decodeBody decodes the JSON body of a response into v.
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body, err)
+	}
+}
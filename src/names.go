@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// normalizeName trims an item name and collapses inner runs of whitespace,
+// then applies -name-case: "lower" lowercases the name and "title"
+// capitalizes every word. Any other value keeps the casing.
+func normalizeName(cfg *Config, name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	switch cfg.NameCase {
+	case "lower":
+		name = strings.ToLower(name)
+	case "title":
+		words := strings.Split(strings.ToLower(name), " ")
+		for i, word := range words {
+			if word == "" {
+				continue
+			}
+			r, size := utf8.DecodeRuneInString(word)
+			words[i] = string(unicode.ToTitle(r)) + word[size:]
+		}
+		name = strings.Join(words, " ")
+	}
+	return name
+}
+
+// normalizeItemName stores the normalized name on an item when
+// -normalize-names is on. With -keep-original-name the name as sent is kept
+// in originalName whenever normalizing changed it.
+func normalizeItemName(cfg *Config, item JSONData) {
+	name, ok := item["name"].(string)
+	if !cfg.NormalizeNames || !ok {
+		return
+	}
+	normalized := normalizeName(cfg, name)
+	if normalized == name {
+		return
+	}
+	item["name"] = normalized
+	if cfg.KeepOriginalName {
+		item["originalName"] = name
+	}
+}
+
+// hasBlankName reports whether an item's name is missing, empty or only
+// whitespace.
+func hasBlankName(item JSONData) bool {
+	name, _ := item["name"].(string)
+	return strings.TrimSpace(name) == ""
+}
+
+// dropBlankNames removes the catalog items with a blank name, and their
+// pending list entries, when -blank-names is "drop". It returns how many
+// items were dropped.
+func dropBlankNames(cfg *Config, data JSONData) int {
+	if cfg.BlankNames != "drop" {
+		return 0
+	}
+	var kept []JSONData
+	var dropped []string
+	for _, item := range catalogItems(data) {
+		if hasBlankName(item) {
+			id, _ := item["id"].(string)
+			dropped = append(dropped, id)
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if len(dropped) == 0 {
+		return 0
+	}
+	setCatalog(data, kept)
+	for _, id := range dropped {
+		if _, err := findItem(data, id); errors.Is(err, errItemNotFound) {
+			replacePendingRefs(data, id, nil)
+		}
+	}
+	return len(dropped)
+}
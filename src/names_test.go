@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	for _, tc := range []struct {
+		nameCase, name, want string
+	}{
+		{"keep", "  Leche  entera ", "Leche entera"},
+		{"keep", "Pan\tde\n molde", "Pan de molde"},
+		{"keep", "aceite DE oliva", "aceite DE oliva"},
+		{"lower", " Aceite DE Oliva", "aceite de oliva"},
+		{"title", "aceite DE oliva  ", "Aceite De Oliva"},
+		{"title", "ñoquis   ÁRABES", "Ñoquis Árabes"},
+		{"title", "   ", ""},
+	} {
+		cfg := newTestConfig(t, "-normalize-names", "-name-case", tc.nameCase)
+		if got := normalizeName(cfg, tc.name); got != tc.want {
+			t.Errorf("%s: normalizeName(%q) = %q, want %q", tc.nameCase, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeNamesOnWrite(t *testing.T) {
+	_, api := newTestAPI(t, "-normalize-names", "-name-case", "lower", "-keep-original-name")
+
+	var item JSONData
+	decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": " Leche  ENTERA"}`, http.StatusCreated), &item)
+	if item["name"] != "leche entera" || item["originalName"] != " Leche  ENTERA" {
+		t.Errorf("created item = %v, want the name normalized and the original kept", item)
+	}
+
+	// Names that are already normalized get no originalName.
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "bread", "name": "pan"}, {"id": "eggs", "name": "Huevos "}], "pendingList": []}`, http.StatusOK)
+	if bread := storedItem(t, api, "bread"); bread["originalName"] != nil {
+		t.Errorf("bread = %v, want no originalName", bread)
+	}
+	if eggs := storedItem(t, api, "eggs"); eggs["name"] != "huevos" || eggs["originalName"] != "Huevos " {
+		t.Errorf("eggs = %v, want the name normalized and the original kept", eggs)
+	}
+
+	// Without -normalize-names names are stored as sent.
+	_, plain := newTestAPI(t)
+	mustDo(t, plain, http.MethodPost, "/data/items", `{"id": "milk", "name": " Leche  ENTERA"}`, http.StatusCreated)
+	if milk := storedItem(t, plain, "milk"); milk["name"] != " Leche  ENTERA" {
+		t.Errorf("name = %q, want it unchanged", milk["name"])
+	}
+}
+
+func TestBlankNames(t *testing.T) {
+	const doc = `{"catalog": [
+		{"id": "milk", "name": "Milk"},
+		{"id": "blank", "name": "   "},
+		{"id": "empty", "name": ""},
+		{"id": "unnamed"}
+	], "pendingList": [{"itemId": "milk", "quantity": 1}, {"itemId": "blank", "quantity": 1}]}`
+
+	_, api := newTestAPI(t, "-blank-names", "reject")
+	rec := mustDo(t, api, http.MethodPut, "/data", doc, http.StatusUnprocessableEntity)
+	if !strings.Contains(rec.Body.String(), "catalog[1].name: must not be blank") {
+		t.Errorf("body %s, want the first blank name pointed at", rec.Body)
+	}
+
+	_, api = newTestAPI(t, "-blank-names", "drop")
+	var result map[string]interface{}
+	decodeBody(t, mustDo(t, api, http.MethodPut, "/data", doc, http.StatusOK), &result)
+	if result["blankNamesDropped"] != 3.0 {
+		t.Errorf("blankNamesDropped = %v, want 3", result["blankNamesDropped"])
+	}
+	var stored Document
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK), &stored)
+	if len(stored.Catalog) != 1 || len(stored.PendingList) != 1 || stored.PendingList[0].ItemID != "milk" {
+		t.Errorf("stored document = %+v, want only milk left", stored)
+	}
+
+	// By default blank names are kept.
+	_, api = newTestAPI(t)
+	mustDo(t, api, http.MethodPut, "/data", doc, http.StatusOK)
+}
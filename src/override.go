@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// methodOverrideHeader lets clients that can only send GET and POST ask for
+// another method.
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// overridableMethods are the methods a POST may be turned into.
+var overridableMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// withMethodOverride honors X-HTTP-Method-Override on POST requests with
+// -allow-method-override, for clients behind proxies that only let GET and
+// POST through. It runs before routing, so the request is handled exactly
+// as if it had been sent with the overriding method. Overrides on other
+// methods or to methods other than PUT, PATCH and DELETE are rejected, since
+// carrying the request out as a POST would do something else than the
+// client asked for. While the option is off the header is ignored.
+func withMethodOverride(cfg *Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.AllowMethodOverride {
+			next.ServeHTTP(w, r)
+			return
+		}
+		override := strings.ToUpper(strings.TrimSpace(r.Header.Get(methodOverrideHeader)))
+		if override == "" || override == r.Method {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case r.Method != http.MethodPost:
+			writeError(w, http.StatusBadRequest, codeBadRequest, methodOverrideHeader+" is only honored on POST requests")
+			return
+		case !slices.Contains(overridableMethods, override):
+			writeError(w, http.StatusBadRequest, codeBadRequest, methodOverrideHeader+" must be one of "+strings.Join(overridableMethods, ", "))
+			return
+		}
+		r.Method = override
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMethodOverride(t *testing.T) {
+	for _, tc := range []struct {
+		args             []string
+		method, override string
+		want             int
+	}{
+		// While the option is off the header is ignored.
+		{nil, http.MethodPost, "DELETE", http.StatusCreated},
+		{nil, http.MethodPost, "TRACE", http.StatusCreated},
+		{nil, http.MethodGet, "DELETE", http.StatusOK},
+		{[]string{"-allow-method-override"}, http.MethodPost, "", http.StatusCreated},
+		{[]string{"-allow-method-override"}, http.MethodPost, "TRACE", http.StatusBadRequest},
+		{[]string{"-allow-method-override"}, http.MethodGet, "DELETE", http.StatusBadRequest},
+	} {
+		s, api := newTestAPI(t, tc.args...)
+		h := withMethodOverride(s.cfg, api)
+		req := httptest.NewRequest(tc.method, "/data/items", strings.NewReader(`{"id": "milk", "name": "Milk"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(methodOverrideHeader, tc.override)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%v: %s with override %q: status %d, want %d; body: %s", tc.args, tc.method, tc.override, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestMethodOverrideReplacesDocument(t *testing.T) {
+	s, api := newTestAPI(t, "-allow-method-override")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+
+	// A POST overridden to PUT replaces the whole document.
+	req := httptest.NewRequest(http.MethodPost, "/data", strings.NewReader(`{"catalog": [], "pendingList": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(methodOverrideHeader, "put")
+	rec := httptest.NewRecorder()
+	withMethodOverride(s.cfg, api).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("overridden PUT: status %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+	var doc Document
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK), &doc)
+	if len(doc.Catalog) != 0 {
+		t.Errorf("catalog = %+v, want it replaced", doc.Catalog)
+	}
+}
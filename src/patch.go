@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// errTestRequired is returned in -patch-conflicts=test mode for patches
+// that change a field without testing its previous value.
+var errTestRequired = errors.New("patches must test every field they replace or remove (-patch-conflicts=test)")
+
+// patchConflictError is returned when a test operation of a PATCH fails
+// because the field no longer has the value the client based its change on,
+// typically because another request changed it in the meantime.
+type patchConflictError struct {
+	field   string
+	current JSONData
+}
+
+func (e *patchConflictError) Error() string {
+	return fmt.Sprintf("field %s was changed by another request", e.field)
+}
+
+// patchOp is one operation of a JSON Patch (RFC 6902). Only the add,
+// replace, remove and test operations on top-level item fields are
+// supported.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// patchField returns the item field a JSON Pointer such as "/name" refers
+// to.
+func patchField(path string) (string, error) {
+	field, ok := strings.CutPrefix(path, "/")
+	if !ok || field == "" || strings.Contains(field, "/") {
+		return "", fmt.Errorf("path %q must point to a top-level item field, such as /name", path)
+	}
+	field = strings.ReplaceAll(strings.ReplaceAll(field, "~1", "/"), "~0", "~")
+	if field == "id" {
+		return "", errors.New("the id of an item can't be patched")
+	}
+	return field, nil
+}
+
+// parsePatch decodes a PATCH body into JSON Patch operations. A JSON Merge
+// Patch (RFC 7396), the default for other content types, is turned into
+// add and remove operations without tests; merge reports that it was one.
+func parsePatch(r *http.Request) (ops []patchOp, merge bool, err error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json-patch+json" {
+		if err := json.Unmarshal(body, &ops); err != nil {
+			return nil, false, err
+		}
+		return ops, false, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, true, errors.New("body must be a JSON object")
+	}
+	ops = make([]patchOp, 0, len(fields))
+	for field, value := range fields {
+		path := "/" + strings.ReplaceAll(strings.ReplaceAll(field, "~", "~0"), "/", "~1")
+		if string(value) == "null" {
+			ops = append(ops, patchOp{Op: "remove", Path: path})
+		} else {
+			ops = append(ops, patchOp{Op: "add", Path: path, Value: value})
+		}
+	}
+	return ops, true, nil
+}
+
+// applyPatch applies ops in order to a copy of item and returns it. A
+// failed test is a *patchConflictError. In test mode, replacing or removing
+// a field requires an earlier test of it, and adding one that meanwhile
+// appeared counts as a conflict, so concurrent changes to the same field
+// can't silently overwrite each other.
+func applyPatch(cfg *Config, item JSONData, ops []patchOp) (JSONData, error) {
+	patched := copyDocument(item)
+	tested := map[string]bool{}
+	for _, op := range ops {
+		field, err := patchField(op.Path)
+		if err != nil {
+			return nil, &validationError{msg: err.Error()}
+		}
+		var value interface{}
+		if op.Op != "remove" {
+			if len(op.Value) == 0 {
+				return nil, &validationError{msg: fmt.Sprintf("operation %s on %s needs a value", op.Op, op.Path)}
+			}
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, &validationError{msg: fmt.Sprintf("invalid value for %s: %v", op.Path, err)}
+			}
+		}
+		current, present := patched[field]
+
+		switch op.Op {
+		case "test":
+			if !present || !reflect.DeepEqual(current, value) {
+				return nil, &patchConflictError{field: field, current: item}
+			}
+			tested[field] = true
+		case "add", "replace", "remove":
+			if op.Op != "add" && !present {
+				return nil, &patchConflictError{field: field, current: item}
+			}
+			if cfg.PatchConflicts == "test" && present && !tested[field] {
+				if op.Op == "add" {
+					return nil, &patchConflictError{field: field, current: item}
+				}
+				return nil, errTestRequired
+			}
+			if op.Op == "remove" {
+				delete(patched, field)
+			} else {
+				patched[field] = value
+			}
+		default:
+			return nil, &validationError{msg: fmt.Sprintf("unsupported patch operation %q", op.Op)}
+		}
+	}
+	return patched, nil
+}
+
+// patchItemHandler handles PATCH /data/items/{id}, changing some fields of
+// an item and leaving the others as they are. The body is a JSON Merge
+// Patch ({"name": "Oat milk", "color": null}) or, with Content-Type
+// application/json-patch+json, a JSON Patch whose test operations make the
+// change conditional on the values the client saw. The patch is applied
+// inside the store's write lock, so concurrent patches to different fields
+// of an item all survive; with -patch-conflicts=test, concurrent changes to
+// the same field are detected too, and all but the first get a 409 with the
+// current item. The patched item is canonicalized, checked against the
+// schema and normalized like the body of POST /data/items.
+func patchItemHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		ops, merge, err := parsePatch(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid patch in request body: "+err.Error())
+			return
+		}
+		if merge && s.cfg.PatchConflicts == "test" {
+			writeError(w, http.StatusPreconditionRequired, codePreconditionRequired, "Send a JSON Patch (application/json-patch+json) testing the fields it changes: "+errTestRequired.Error())
+			return
+		}
+
+		var updated JSONData
+		err = s.update(r.Context(), func(data JSONData) error {
+			item, err := findItem(data, mux.Vars(r)["id"])
+			if err != nil {
+				return err
+			}
+			patched, err := applyPatch(s.cfg, item, ops)
+			if err != nil {
+				return err
+			}
+			if s.cfg.CanonicalizeValues {
+				if err := canonicalizeItem(s.cfg, patched); err != nil {
+					return err
+				}
+			}
+			if err := checkItemChanges(s.cfg, item, patched); err != nil {
+				return err
+			}
+			normalizeItemName(s.cfg, patched)
+			if name, _ := patched["name"].(string); strings.TrimSpace(name) == "" {
+				return &validationError{msg: "Item name is required"}
+			}
+			if err := validateItem(s.cfg, patched); err != nil {
+				return err
+			}
+			if reflect.DeepEqual(item, patched) {
+				updated = item
+				return errUnchanged
+			}
+			for field := range item {
+				delete(item, field)
+			}
+			for field, value := range patched {
+				item[field] = value
+			}
+			updated = item
+			return nil
+		})
+		if errors.Is(err, errTestRequired) {
+			writeError(w, http.StatusPreconditionRequired, codePreconditionRequired, err.Error())
+			return
+		}
+		writeItemResult(w, r, updated, err)
+	}
+}
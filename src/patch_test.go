@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// patchItem sends a PATCH with the given Content-Type.
+func patchItem(h http.Handler, id, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/data/items/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// concurrently runs the PATCH bodies at the same time and returns their
+// responses in the same order.
+func concurrently(h http.Handler, id, contentType string, bodies ...string) []*httptest.ResponseRecorder {
+	recs := make([]*httptest.ResponseRecorder, len(bodies))
+	var wg sync.WaitGroup
+	for i, body := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recs[i] = patchItem(h, id, contentType, body)
+		}()
+	}
+	wg.Wait()
+	return recs
+}
+
+// storedItem returns the item id as GET /data/items lists it.
+func storedItem(t *testing.T, api http.Handler, id string) JSONData {
+	t.Helper()
+	var items []JSONData
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data/items", "", http.StatusOK), &items)
+	for _, item := range items {
+		if item["id"] == id {
+			return item
+		}
+	}
+	t.Fatalf("item %s not found", id)
+	return nil
+}
+
+func TestConcurrentPatchesMerge(t *testing.T) {
+	_, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+
+	// Both changes to the same field succeed and the last one wins.
+	recs := concurrently(api, "milk", "application/merge-patch+json",
+		`{"name": "Oat milk", "color": "blue"}`, `{"name": "Soy milk", "category": "Dairy"}`)
+	for _, rec := range recs {
+		if rec.Code != http.StatusOK {
+			t.Fatalf("merge patch: status %d, want 200; body: %s", rec.Code, rec.Body)
+		}
+	}
+	item := storedItem(t, api, "milk")
+	if name := item["name"]; name != "Oat milk" && name != "Soy milk" {
+		t.Errorf("name = %v, want one of the patched names", name)
+	}
+	// Changes to different fields both survive.
+	if item["color"] != "blue" || item["category"] != "Dairy" {
+		t.Errorf("item = %v, want the color and category of both patches", item)
+	}
+}
+
+func TestConcurrentPatchesTest(t *testing.T) {
+	_, api := newTestAPI(t, "-patch-conflicts", "test")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+
+	// Both patches saw the name Milk: only the first one applied may change it.
+	recs := concurrently(api, "milk", "application/json-patch+json",
+		`[{"op": "test", "path": "/name", "value": "Milk"}, {"op": "replace", "path": "/name", "value": "Oat milk"}]`,
+		`[{"op": "test", "path": "/name", "value": "Milk"}, {"op": "replace", "path": "/name", "value": "Soy milk"}]`)
+	var won, conflicts int
+	for _, rec := range recs {
+		switch rec.Code {
+		case http.StatusOK:
+			won++
+		case http.StatusConflict:
+			conflicts++
+			if !strings.Contains(rec.Body.String(), codePatchConflict) {
+				t.Errorf("conflict body %s, want %s", rec.Body, codePatchConflict)
+			}
+		default:
+			t.Fatalf("json patch: status %d; body: %s", rec.Code, rec.Body)
+		}
+	}
+	if won != 1 || conflicts != 1 {
+		t.Fatalf("%d patches applied and %d conflicted, want 1 and 1", won, conflicts)
+	}
+
+	// Untested changes are refused outright.
+	if rec := patchItem(api, "milk", "application/merge-patch+json", `{"name": "Rice milk"}`); rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("merge patch in test mode: status %d, want 428", rec.Code)
+	}
+}
+
+func TestPatchNormalizesLikeCreate(t *testing.T) {
+	_, api := newTestAPI(t, "-canonicalize-values", "-normalize-names", "-name-case", "title")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk", "color": "blue"}`, http.StatusCreated)
+
+	rec := patchItem(api, "milk", "application/merge-patch+json", `{"name": "  oat   milk ", "archived": "true"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+	var item JSONData
+	decodeBody(t, rec, &item)
+	if item["name"] != "Oat Milk" || item["archived"] != true {
+		t.Fatalf("patched item = %v, want the name normalized and archived coerced to true", item)
+	}
+
+	// Values must have the schema's types, as when creating an item.
+	if rec := patchItem(api, "milk", "application/merge-patch+json", `{"store": 5}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("patching store to a number: status %d, want 422; body: %s", rec.Code, rec.Body)
+	}
+}
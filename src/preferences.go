@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// knownPreferences lists the UI preferences GET and PUT /settings accept,
+// with their allowed values.
+var knownPreferences = map[string][]string{
+	"theme":       {"light", "dark", "system"},
+	"defaultSort": {"catalog", "name", "route"},
+	"units":       {"original", "metric"},
+}
+
+// loadPreferences returns the stored UI preferences.
+func (s *Store) loadPreferences() (map[string]string, error) {
+	preferences := map[string]string{}
+	if err := s.preferences.load(&preferences); err != nil {
+		return nil, err
+	}
+	return preferences, nil
+}
+
+// parsePreferences validates a PUT /settings body. Unknown keys are dropped,
+// or rejected with -reject-unknown-preferences.
+func parsePreferences(cfg *Config, raw map[string]interface{}) (map[string]string, error) {
+	preferences := map[string]string{}
+	for key, value := range raw {
+		allowed, known := knownPreferences[key]
+		if !known {
+			if cfg.RejectUnknownPreferences {
+				return nil, fmt.Errorf("unknown preference %q, expected one of %s", key, strings.Join(preferenceKeys(), ", "))
+			}
+			continue
+		}
+		str, ok := value.(string)
+		if !ok || !slices.Contains(allowed, str) {
+			return nil, fmt.Errorf("preference %s must be one of %s", key, strings.Join(allowed, ", "))
+		}
+		preferences[key] = str
+	}
+	return preferences, nil
+}
+
+// preferencesHandler handles GET and PUT /settings, the UI preferences of
+// the web client such as its theme, default sort and units. They are kept in
+// -preferences-file rather than in the list document, so they never show up
+// among the items. PUT replaces all preferences; the known keys are listed
+// in knownPreferences.
+func preferencesHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			preferences, err := s.loadPreferences()
+			if err != nil {
+				log.Printf("Error in GET /settings: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			writeJSON(w, http.StatusOK, preferences)
+
+		case http.MethodPut:
+			var raw map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&raw); err != nil || raw == nil {
+				writeError(w, http.StatusBadRequest, codeInvalidJSON, "Request body must be a JSON object")
+				return
+			}
+			preferences, err := parsePreferences(s.cfg, raw)
+			if err != nil {
+				writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
+				return
+			}
+			if err := s.preferences.save(preferences); err != nil {
+				log.Printf("Error in PUT /settings: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error: Failed to save preferences")
+				return
+			}
+			writeJSON(w, http.StatusOK, preferences)
+
+		default:
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+		}
+	}
+}
+
+// preferenceKeys returns the known preference keys in order, for messages.
+func preferenceKeys() []string {
+	keys := make([]string, 0, len(knownPreferences))
+	for key := range knownPreferences {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
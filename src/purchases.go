@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// purchaseRecord is how often an item has been bought and when last.
+type purchaseRecord struct {
+	Count         int       `json:"count"`
+	LastPurchased time.Time `json:"lastPurchased"`
+}
+
+// PurchaseLog keeps per-item purchase counts in a file of its own, so the
+// history survives items leaving the pending list without bloating the data
+// file the web client loads.
+type PurchaseLog struct {
+	filepath string
+	mu       sync.RWMutex
+	records  map[string]purchaseRecord
+}
+
+// NewPurchaseLog loads the purchase history from path. A missing file starts
+// an empty history.
+func NewPurchaseLog(path string) (*PurchaseLog, error) {
+	p := &PurchaseLog{filepath: path, records: map[string]purchaseRecord{}}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading purchase history: %w", err)
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &p.records); err != nil {
+			return nil, fmt.Errorf("error unmarshaling purchase history: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// record counts one purchase of each item id and persists the history.
+func (p *PurchaseLog) record(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, id := range ids {
+		rec := p.records[id]
+		rec.Count++
+		rec.LastPurchased = now
+		p.records[id] = rec
+	}
+	return p.save()
+}
+
+// replaceAll overwrites the whole purchase history, as when restoring a
+// backup.
+func (p *PurchaseLog) replaceAll(records map[string]purchaseRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.records = records
+	return p.save()
+}
+
+// save persists the history. Callers must hold p.mu.
+func (p *PurchaseLog) save() error {
+	content, err := json.MarshalIndent(p.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling purchase history: %w", err)
+	}
+	if err := writeFileAtomic(p.filepath, content, 0644); err != nil {
+		return fmt.Errorf("error writing purchase history: %w", err)
+	}
+	return nil
+}
+
+// snapshot returns a copy of the recorded purchases.
+func (p *PurchaseLog) snapshot() map[string]purchaseRecord {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	records := make(map[string]purchaseRecord, len(p.records))
+	for id, rec := range p.records {
+		records[id] = rec
+	}
+	return records
+}
+
+// pendingItemIDs returns the set of item ids currently on the pending list.
+func pendingItemIDs(data JSONData) map[string]bool {
+	ids := map[string]bool{}
+	raw, _ := data[pendingKey].([]interface{})
+	for _, entry := range raw {
+		if ref, ok := entry.(map[string]interface{}); ok {
+			if itemID, ok := ref["itemId"].(string); ok {
+				ids[itemID] = true
+			}
+		}
+	}
+	return ids
+}
+
+// boughtItems compares two versions of the document and returns the ids of
+// items that left the pending list while staying in the catalog. That's what
+// ticking an item off in the web client does; items deleted from the catalog
+// altogether were not bought.
+func boughtItems(before, after JSONData) []string {
+	stillPending := pendingItemIDs(after)
+	var bought []string
+	for id := range pendingItemIDs(before) {
+		if stillPending[id] {
+			continue
+		}
+		if _, err := findItem(after, id); err == nil {
+			bought = append(bought, id)
+		}
+	}
+	sort.Strings(bought)
+	return bought
+}
+
+// suggestionsHandler handles GET /suggestions?n=5, returning up to n active
+// catalog items that are not on the pending list, most frequently bought
+// first (ties broken by the most recent purchase, then -sort-tie-breaker).
+// Fewer items are returned when the purchase history is sparse.
+func suggestionsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		n := 5
+		if v := r.URL.Query().Get("n"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Parameter n must be a positive integer")
+				return
+			}
+			n = parsed
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /suggestions: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		type suggestion struct {
+			Item          JSONData  `json:"item"`
+			PurchaseCount int       `json:"purchaseCount"`
+			LastPurchased time.Time `json:"lastPurchased"`
+		}
+		records := s.purchases.snapshot()
+		pending := pendingItemIDs(data)
+		suggestions := []suggestion{}
+		for _, item := range activeItems(data) {
+			id, _ := item["id"].(string)
+			rec, ok := records[id]
+			if !ok || pending[id] {
+				continue
+			}
+			suggestions = append(suggestions, suggestion{Item: item, PurchaseCount: rec.Count, LastPurchased: rec.LastPurchased})
+		}
+		sort.SliceStable(suggestions, func(i, j int) bool {
+			if suggestions[i].PurchaseCount != suggestions[j].PurchaseCount {
+				return suggestions[i].PurchaseCount > suggestions[j].PurchaseCount
+			}
+			if !suggestions[i].LastPurchased.Equal(suggestions[j].LastPurchased) {
+				return suggestions[i].LastPurchased.After(suggestions[j].LastPurchased)
+			}
+			return itemsTieBreak(s.cfg, suggestions[i].Item, suggestions[j].Item) < 0
+		})
+		if len(suggestions) > n {
+			suggestions = suggestions[:n]
+		}
+
+		writeJSON(w, http.StatusOK, suggestions)
+	}
+}
+
+// nameSuggestion is an entry of GET /data/items/suggestions.
+type nameSuggestion struct {
+	Name          string    `json:"name"`
+	PurchaseCount int       `json:"purchaseCount"`
+	LastPurchased time.Time `json:"lastPurchased"`
+}
+
+// itemNameSuggestionsHandler handles GET /data/items/suggestions?limit=10,
+// returning up to limit item names the purchase history says are bought
+// often but are not on the pending list, most frequently bought first. Unlike
+// GET /suggestions it also draws on archived items, and items that share a
+// name (ignoring case and spacing) add up as one name, so it suggests what
+// to buy rather than which catalog entry to pick.
+func itemNameSuggestionsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		limit := 10
+		if v := r.URL.Query().Get("limit"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Parameter limit must be a positive integer")
+				return
+			}
+			limit = parsed
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /data/items/suggestions: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+
+		nameKey := func(item JSONData) string {
+			name, _ := item["name"].(string)
+			return strings.ToLower(strings.Join(strings.Fields(name), " "))
+		}
+		pending := pendingItemIDs(data)
+		onList := map[string]bool{}
+		for _, item := range catalogItems(data) {
+			if id, _ := item["id"].(string); pending[id] {
+				onList[nameKey(item)] = true
+			}
+		}
+
+		records := s.purchases.snapshot()
+		byName := map[string]*nameSuggestion{}
+		var suggestions []*nameSuggestion
+		for _, item := range catalogItems(data) {
+			id, _ := item["id"].(string)
+			rec, ok := records[id]
+			key := nameKey(item)
+			if !ok || key == "" || onList[key] {
+				continue
+			}
+			suggestion, seen := byName[key]
+			if !seen {
+				name, _ := item["name"].(string)
+				suggestion = &nameSuggestion{Name: strings.TrimSpace(name)}
+				byName[key] = suggestion
+				suggestions = append(suggestions, suggestion)
+			}
+			suggestion.PurchaseCount += rec.Count
+			if rec.LastPurchased.After(suggestion.LastPurchased) {
+				suggestion.LastPurchased = rec.LastPurchased
+			}
+		}
+		sort.SliceStable(suggestions, func(i, j int) bool {
+			if suggestions[i].PurchaseCount != suggestions[j].PurchaseCount {
+				return suggestions[i].PurchaseCount > suggestions[j].PurchaseCount
+			}
+			if !suggestions[i].LastPurchased.Equal(suggestions[j].LastPurchased) {
+				return suggestions[i].LastPurchased.After(suggestions[j].LastPurchased)
+			}
+			return strings.ToLower(suggestions[i].Name) < strings.ToLower(suggestions[j].Name)
+		})
+		if len(suggestions) > limit {
+			suggestions = suggestions[:limit]
+		}
+
+		result := make([]nameSuggestion, len(suggestions))
+		for i, suggestion := range suggestions {
+			result[i] = *suggestion
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
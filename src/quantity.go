@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// checkQuantities rejects quantities above -max-quantity, and negative ones
+// with -reject-negative-quantities, in the catalog items and pending entries
+// a write adds or changes. Entries left as they were are not checked, so a
+// stricter limit doesn't block writes to a list that already breaks it.
+func checkQuantities(cfg *Config, before, after JSONData) error {
+	if cfg.MaxQuantity <= 0 && !cfg.RejectNegativeQuantities {
+		return nil
+	}
+	old, current := flattenDocument(before), flattenDocument(after)
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reflect.DeepEqual(old[key], current[key]) {
+			continue
+		}
+		var entries []interface{}
+		switch value := current[key].(type) {
+		case map[string]interface{}:
+			entries = []interface{}{value}
+		case []interface{}:
+			// A keyed array that couldn't be split per entry.
+			if _, keyed := keyedArrays[key]; keyed {
+				entries = value
+			}
+		}
+		for _, entry := range entries {
+			obj, _ := entry.(map[string]interface{})
+			quantity, ok := obj["quantity"].(float64)
+			if !ok {
+				continue
+			}
+			if err := checkQuantity(cfg, quantity); err != nil {
+				return &validationError{msg: fmt.Sprintf("%s.quantity: %s", key, err)}
+			}
+		}
+	}
+	return nil
+}
+
+// checkQuantity checks a single quantity against the configured limits.
+func checkQuantity(cfg *Config, quantity float64) error {
+	value := strconv.FormatFloat(quantity, 'f', -1, 64)
+	if cfg.RejectNegativeQuantities && quantity < 0 {
+		return fmt.Errorf("%s is negative", value)
+	}
+	if cfg.MaxQuantity > 0 && quantity > cfg.MaxQuantity {
+		return fmt.Errorf("%s exceeds the maximum of %s", value, strconv.FormatFloat(cfg.MaxQuantity, 'f', -1, 64))
+	}
+	return nil
+}
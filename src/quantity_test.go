@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaxQuantity(t *testing.T) {
+	_, api := newTestAPI(t, "-max-quantity", "10")
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "eggs", "name": "Eggs", "quantity": 10}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "bad", "name": "Negative", "quantity": -1}`, http.StatusCreated)
+	for _, tc := range []struct{ method, path, body, want string }{
+		{http.MethodPost, "/data/items", `{"name": "Milk", "quantity": 10.5}`, "10.5 exceeds the maximum of 10"},
+		{http.MethodPatch, "/data/items/eggs", `{"quantity": 1000}`, "1000 exceeds the maximum of 10"},
+		{http.MethodPut, "/data", `{"catalog": [{"id": "eggs", "name": "Eggs"}], "pendingList": [{"itemId": "eggs", "quantity": 12}]}`, "12 exceeds the maximum of 10"},
+	} {
+		rec := mustDo(t, api, tc.method, tc.path, tc.body, http.StatusUnprocessableEntity)
+		if !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s %s: body %s, want %q", tc.method, tc.path, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestRejectNegativeQuantities(t *testing.T) {
+	_, api := newTestAPI(t, "-reject-negative-quantities")
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "eggs", "name": "Eggs", "quantity": 0}`, http.StatusCreated)
+	rec := mustDo(t, api, http.MethodPatch, "/data/items/eggs", `{"quantity": -0.5}`, http.StatusUnprocessableEntity)
+	if !strings.Contains(rec.Body.String(), "-0.5 is negative") {
+		t.Errorf("body %s, want the negative value named", rec.Body)
+	}
+}
+
+func TestQuantityLimitsSkipUnchangedEntries(t *testing.T) {
+	cfg := newTestConfig(t, "-max-quantity", "10")
+	before := JSONData{catalogKey: []interface{}{map[string]interface{}{"id": "rice", "name": "Rice", "quantity": 500.0}}}
+
+	// A stricter limit doesn't block writes to other items.
+	after := copyDocument(before)
+	setCatalog(after, append(catalogItems(after), JSONData{"id": "eggs", "name": "Eggs", "quantity": 6.0}))
+	if err := checkQuantities(cfg, before, after); err != nil {
+		t.Errorf("adding an item within the limit: %v", err)
+	}
+
+	catalogItems(after)[0]["quantity"] = 600.0
+	if err := checkQuantities(cfg, before, after); err == nil {
+		t.Error("changing a quantity above the limit was accepted")
+	}
+}
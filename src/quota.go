@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestQuota counts the requests of every client IP per UTC day, so one
+// abusive client can't monopolize a public instance over long periods. The
+// counts are flushed to a file every few seconds and on shutdown, so a
+// restart doesn't hand out a fresh quota.
+type RequestQuota struct {
+	cfg   *Config
+	file  *sidecarFile
+	mu    sync.Mutex
+	state quotaState
+	dirty bool
+}
+
+// quotaState is the on-disk form of the RequestQuota.
+type quotaState struct {
+	Day    string         `json:"day"`
+	Counts map[string]int `json:"counts"`
+}
+
+// NewRequestQuota loads the counts of the current day from path. The limit
+// is read from cfg on every request, so a reload applies to it right away.
+func NewRequestQuota(cfg *Config, path string) (*RequestQuota, error) {
+	q := &RequestQuota{cfg: cfg, file: &sidecarFile{path: path}}
+	if err := q.file.load(&q.state); err != nil {
+		return nil, err
+	}
+	if q.state.Counts == nil {
+		q.state.Counts = map[string]int{}
+	}
+	return q, nil
+}
+
+// take counts a request from ip against limit and reports how many remain
+// today, when the quota resets and whether the request is within the quota.
+func (q *RequestQuota) take(ip string, limit int, now time.Time) (remaining int, reset time.Time, ok bool) {
+	now = now.UTC()
+	day := now.Format(time.DateOnly)
+	reset = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.state.Day != day {
+		q.state = quotaState{Day: day, Counts: map[string]int{}}
+	}
+	if q.state.Counts[ip] >= limit {
+		return 0, reset, false
+	}
+	q.state.Counts[ip]++
+	q.dirty = true
+	return limit - q.state.Counts[ip], reset, true
+}
+
+// flush saves the counts if they changed since the last flush.
+func (q *RequestQuota) flush() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.dirty {
+		return nil
+	}
+	if err := q.file.save(q.state); err != nil {
+		return err
+	}
+	q.dirty = false
+	return nil
+}
+
+// flushEvery flushes the counts every interval for the lifetime of the
+// server.
+func (q *RequestQuota) flushEvery(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := q.flush(); err != nil {
+			log.Printf("Error saving request quota: %v", err)
+		}
+	}
+}
+
+// clientIP returns the address of the client that sent r. When the peer is
+// one of -trusted-proxies, the client is the last address in
+// X-Forwarded-For that isn't itself a trusted proxy, since each proxy
+// appends the address it got the request from. The header of any other peer
+// is ignored: clients can send it with any value.
+func clientIP(cfg *Config, r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(hops) - 1; i >= 0 && trustedProxy(cfg, ip); i-- {
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		ip = hops[i]
+	}
+	return ip
+}
+
+// trustedProxy tells whether ip is in one of -trusted-proxies.
+func trustedProxy(cfg *Config, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range cfg.TrustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaMiddleware enforces the daily request quota per client IP. Every
+// response carries X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (unix seconds); once the quota is used up requests get a
+// 429 with Retry-After until the next UTC day. A quota reloaded to zero
+// lets requests through uncounted.
+func quotaMiddleware(q *RequestQuota) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := q.cfg.Live().DailyQuota
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip := clientIP(q.cfg, r)
+			now := time.Now()
+			remaining, reset, ok := q.take(ip, limit, now)
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+				writeError(w, http.StatusTooManyRequests, codeQuotaExceeded, fmt.Sprintf("Too Many Requests: daily quota exhausted, resets at %s", reset.Format(time.RFC3339)))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	cfg := newTestConfig(t, "-trusted-proxies", "10.42.0.0/16, 192.0.2.7")
+	for _, tc := range []struct {
+		remote, forwarded, want string
+	}{
+		{"203.0.113.5:4000", "", "203.0.113.5"},
+		// Untrusted peers can't pick the address they are counted as.
+		{"203.0.113.5:4000", "198.51.100.1", "203.0.113.5"},
+		{"10.42.0.9:4000", "198.51.100.1", "198.51.100.1"},
+		// Addresses prepended by the client are ignored.
+		{"10.42.0.9:4000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		// Chained proxies are skipped.
+		{"10.42.0.9:4000", "198.51.100.1, 192.0.2.7", "198.51.100.1"},
+		{"10.42.0.9:4000", "garbage", "10.42.0.9"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/data", nil)
+		r.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if got := clientIP(cfg, r); got != tc.want {
+			t.Errorf("clientIP(%s, X-Forwarded-For %q) = %s, want %s", tc.remote, tc.forwarded, got, tc.want)
+		}
+	}
+}
+
+func TestQuotaPerForwardedClient(t *testing.T) {
+	cfg := newTestConfig(t, "-daily-quota", "1", "-trusted-proxies", "10.42.0.0/16")
+	q, err := NewRequestQuota(cfg, cfg.QuotaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := quotaMiddleware(q)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	get := func(client string) int {
+		r := httptest.NewRequest(http.MethodGet, "/data", nil)
+		r.RemoteAddr = "10.42.0.9:4000"
+		r.Header.Set("X-Forwarded-For", client)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Clients behind the same ingress each get their own quota.
+	if get("198.51.100.1") != http.StatusOK || get("198.51.100.2") != http.StatusOK {
+		t.Fatal("first request of each client was refused")
+	}
+	if code := get("198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", code)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// repairData normalizes a document into the shape the web client expects
+// and returns a description of every change made. It is safe to run on data
+// that is already canonical, in which case nothing is reported.
+func repairData(data JSONData) []string {
+	var changes []string
+
+	for _, key := range []string{catalogKey, pendingKey} {
+		if _, ok := data[key].([]interface{}); !ok {
+			if _, present := data[key]; present {
+				changes = append(changes, fmt.Sprintf("replaced non-array %s with an empty list", key))
+			} else {
+				changes = append(changes, fmt.Sprintf("added missing %s", key))
+			}
+			data[key] = []interface{}{}
+		}
+	}
+
+	raw := data[catalogKey].([]interface{})
+	items := catalogItems(data)
+	if dropped := len(raw) - len(items); dropped > 0 {
+		changes = append(changes, fmt.Sprintf("dropped %d catalog entries that are not objects", dropped))
+		setCatalog(data, items)
+	}
+
+	known := make(map[string]bool, len(items))
+	for _, item := range items {
+		id, _ := item["id"].(string)
+		known[id] = true
+		if _, ok := item["tags"]; !ok {
+			continue
+		}
+		tags := itemTags(item)
+		if stored, _ := item["tags"].([]interface{}); len(stored) != len(tags) || !slices.EqualFunc(stored, tags, func(a interface{}, b string) bool { return a == b }) {
+			changes = append(changes, fmt.Sprintf("normalized tags of item %q", id))
+			setItemTags(item, tags)
+		}
+	}
+
+	pending := data[pendingKey].([]interface{})
+	kept := pending[:0]
+	for _, entry := range pending {
+		ref, ok := entry.(map[string]interface{})
+		itemID, _ := ref["itemId"].(string)
+		if !ok || !known[itemID] {
+			changes = append(changes, fmt.Sprintf("dropped pending entry for unknown item %q", itemID))
+			continue
+		}
+		// Fractions such as 0.5 kg are fine; only a missing or non-positive
+		// quantity can't be shopped for.
+		if quantity, ok := ref["quantity"].(float64); !ok || quantity <= 0 {
+			changes = append(changes, fmt.Sprintf("reset invalid quantity of pending item %q to 1", itemID))
+			ref["quantity"] = float64(1)
+		}
+		kept = append(kept, ref)
+	}
+	data[pendingKey] = kept
+
+	return changes
+}
+
+// repairOnStart runs repairData over the stored document and persists the
+// result, logging each change. The file is not rewritten when it is already
+// in canonical shape.
+func repairOnStart(s *Store) error {
+	return s.update(context.Background(), func(data JSONData) error {
+		changes := repairData(data)
+		if len(changes) == 0 {
+			log.Printf("Repair: %s is already in canonical shape", s.filepath)
+			return errUnchanged
+		}
+		for _, change := range changes {
+			log.Printf("Repair: %s", change)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+)
+
+// pendingQuantities returns the quantity of every pending entry by item id.
+func pendingQuantities(data JSONData) map[string]interface{} {
+	quantities := map[string]interface{}{}
+	for _, entry := range data[pendingKey].([]interface{}) {
+		ref := entry.(map[string]interface{})
+		quantities[ref["itemId"].(string)] = ref["quantity"]
+	}
+	return quantities
+}
+
+func TestRepairKeepsFractionalQuantities(t *testing.T) {
+	var data JSONData
+	if err := json.Unmarshal([]byte(`{
+		"catalog": [{"id": "a"}, {"id": "b"}, {"id": "c"}, {"id": "d"}, {"id": "e"}],
+		"pendingList": [
+			{"itemId": "a", "quantity": 0.5},
+			{"itemId": "b", "quantity": 0},
+			{"itemId": "c", "quantity": -2},
+			{"itemId": "d", "quantity": "two"},
+			{"itemId": "e"}
+		]
+	}`), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	changes := repairData(data)
+	want := map[string]interface{}{"a": 0.5, "b": 1.0, "c": 1.0, "d": 1.0, "e": 1.0}
+	if got := pendingQuantities(data); !maps.Equal(got, want) {
+		t.Errorf("quantities = %v, want %v", got, want)
+	}
+	if len(changes) != 4 {
+		t.Errorf("changes = %q, want the four invalid quantities reset", changes)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can still flush.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// requestLogMiddleware logs every request at debug level and emits a warning
+// when one takes longer than -slow-request-threshold, which usually points
+// at slow disk writes or oversized payloads. Event streams stay open for as
+// long as their client is connected, so they are never reported as slow.
+func requestLogMiddleware(cfg *Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			duration := time.Since(start)
+
+			level := slog.LevelDebug
+			msg := "Request handled"
+			if threshold := cfg.Live().SlowRequestThreshold; threshold > 0 && duration > threshold && !isEventStream(rec) {
+				level = slog.LevelWarn
+				msg = "Slow request"
+			}
+			slog.Log(r.Context(), level, msg,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", rec.status,
+				"duration", duration,
+			)
+		})
+	}
+}
+
+// isEventStream reports whether a response is a Server-Sent Events stream.
+func isEventStream(w http.ResponseWriter) bool {
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSlowRequestWarning(t *testing.T) {
+	var logged bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logged, nil)))
+
+	cfg := newTestConfig(t, "-slow-request-threshold", "1ms")
+	for _, tc := range []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json", true},
+		// Event streams are open for as long as the client stays.
+		{"text/event-stream; charset=utf-8", false},
+	} {
+		logged.Reset()
+		h := requestLogMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			time.Sleep(5 * time.Millisecond)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/data/events", nil))
+		if got := strings.Contains(logged.String(), "Slow request"); got != tc.want {
+			t.Errorf("%s: slow request logged = %v, want %v; log: %s", tc.contentType, got, tc.want, logged.String())
+		}
+	}
+}
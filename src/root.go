@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// documentShape shows the expected shape of the document in error messages.
+const documentShape = `{"catalog": [...], "pendingList": [...]}`
+
+// documentRoot checks that a document body is a JSON object, since that is
+// what the store keeps. A top-level array is a common mistake, such as
+// posting the catalog on its own: it is rejected with an error explaining
+// the expected shape, or with -allow-array-root taken as the catalog and
+// wrapped into an object. Bodies that are not valid JSON at all are returned
+// as they are, for the caller's parser to report.
+func documentRoot(cfg *Config, body []byte) ([]byte, error) {
+	tok, err := json.NewDecoder(bytes.NewReader(body)).Token()
+	if err != nil {
+		return body, nil
+	}
+	switch tok {
+	case json.Delim('{'):
+		return body, nil
+	case json.Delim('['):
+		if cfg.AllowArrayRoot {
+			wrapped := append([]byte(`{"`+catalogKey+`":`), body...)
+			return append(wrapped, '}'), nil
+		}
+		return nil, fmt.Errorf("the document must be a JSON object such as %s, got an array", documentShape)
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return body, nil
+	}
+	got := "a " + jsonTypeOf(value)
+	if value == nil {
+		got = "null"
+	}
+	return nil, fmt.Errorf("the document must be a JSON object such as %s, got %s", documentShape, got)
+}
+
+// parseDocument turns the body of a whole-document write into the document
+// to store, the same way for every endpoint that writes one: the root must
+// be an object (see documentRoot), duplicate keys are rejected with
+// -reject-duplicate-keys, the body must match Document with -strict-json,
+// and item names and values are normalized with -normalize-names and
+// -canonicalize-values. Values that can't be canonicalized are reported as
+// a *validationError; any other error means a malformed body. Uploads go
+// through decodeUpload first.
+func parseDocument(cfg *Config, body []byte) (JSONData, error) {
+	body, err := documentRoot(cfg, body)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RejectDuplicateKeys {
+		if err := checkDuplicateKeys(body); err != nil {
+			return nil, err
+		}
+	}
+	// In strict mode the body must also match the typed document schema.
+	if cfg.StrictJSON {
+		if err := decodeJSON(cfg, bytes.NewReader(body), &Document{}); err != nil {
+			return nil, err
+		}
+	}
+
+	var data JSONData
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	for _, item := range catalogItems(data) {
+		normalizeItemName(cfg, item)
+	}
+	if cfg.CanonicalizeValues {
+		if err := canonicalizeDocument(cfg, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
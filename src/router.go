@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// handle registers h on path for the methods it accepts, which are what
+// OPTIONS requests and CORS preflights report for the route (see
+// preflightMethods). A second route on the same path answers every other
+// method, so a request with one the route doesn't handle never falls
+// through to the static website: OPTIONS gets 204 and 405 anything else,
+// both with an Allow header listing the route's methods.
+func handle(router *mux.Router, path string, h http.HandlerFunc, methods ...string) {
+	router.HandleFunc(path, h).Methods(methods...)
+	allow := strings.Join(append([]string{http.MethodOptions}, methods...), ", ")
+	router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+	})
+}
+
+// NewRouter registers the API routes along with the request logging
+// middleware. Response headers are set by newServer, around everything that
+// may answer a request. The static website is mounted by
+// the caller, either on the same router or on a listener of its own.
+func NewRouter(cfg *Config, store *Store) *mux.Router {
+	router := mux.NewRouter()
+	if cfg.ServerTiming {
+		router.Use(serverTimingMiddleware)
+	}
+	router.Use(requestLogMiddleware(cfg))
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Not Found")
+	})
+
+	handle(router, "/data", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getDataHandler(store)(w, r)
+		case http.MethodPost, http.MethodPut:
+			updateDataHandler(store)(w, r)
+		}
+	}, http.MethodGet, http.MethodPost, http.MethodPut)
+
+	handle(router, "/health", healthHandler(store), http.MethodGet)
+	handle(router, "/status", statusHandler(store), http.MethodGet)
+	handle(router, "/config", configHandler(store), http.MethodGet)
+	handle(router, "/suggestions", suggestionsHandler(store), http.MethodGet)
+	handle(router, "/facets", facetsHandler(store), http.MethodGet)
+	handle(router, "/autocomplete", autocompleteHandler(store), http.MethodGet)
+	handle(router, "/stats/activity", activityHandler(store), http.MethodGet)
+	handle(router, "/schema", schemaHandler(store), http.MethodGet)
+	handle(router, "/settings", preferencesHandler(store), http.MethodGet, http.MethodPut)
+	handle(router, "/settings/sections", sectionsHandler(store), http.MethodGet, http.MethodPut)
+
+	handle(router, "/data/items", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listItemsHandler(store)(w, r)
+		case http.MethodPost:
+			createItemHandler(store)(w, r)
+		case http.MethodDelete:
+			deleteItemsHandler(store)(w, r)
+		}
+	}, http.MethodGet, http.MethodPost, http.MethodDelete)
+	handle(router, "/import.txt", importTextHandler(store), http.MethodPost)
+	handle(router, "/export/all", exportAllHandler(store), http.MethodGet)
+	handle(router, "/export.html", exportHTMLHandler(store), http.MethodGet)
+	handle(router, "/import/all", importAllHandler(store), http.MethodPost)
+	handle(router, "/import-url", importURLHandler(store), http.MethodPost)
+
+	handle(router, "/data/items/split", splitItemHandler(store), http.MethodPost)
+	handle(router, "/data/items/suggestions", itemNameSuggestionsHandler(store), http.MethodGet)
+	handle(router, "/data/items/combine", combineItemsHandler(store), http.MethodPost)
+	handle(router, "/data/items/{id}", patchItemHandler(store), http.MethodPatch)
+	handle(router, "/data/items/{id}/archive", archiveItemHandler(store, true), http.MethodPost)
+	handle(router, "/data/items/{id}/unarchive", archiveItemHandler(store, false), http.MethodPost)
+	handle(router, "/data/archived", listArchivedHandler(store), http.MethodGet)
+	handle(router, "/data/tree", treeHandler(store), http.MethodGet)
+	handle(router, "/data/grouped-by-checked", groupedByCheckedHandler(store), http.MethodGet)
+	handle(router, "/data/changes", changesHandler(store), http.MethodGet)
+	handle(router, "/data/events", eventsHandler(store), http.MethodGet)
+	handle(router, "/data/hash", dataHashHandler(store), http.MethodGet)
+	handle(router, "/data/touch", touchHandler(store), http.MethodPost)
+	handle(router, "/data/categories/completed", clearCompletedHandler(store), http.MethodDelete)
+	// Nested categories are named by their path, slashes included.
+	handle(router, "/data/categories/{name:.+}/complete", completeCategoryHandler(store), http.MethodPost, http.MethodDelete)
+	handle(router, "/data/items/{id}/tags", addItemTagHandler(store), http.MethodPost)
+	handle(router, "/data/items/{id}/tags/{tag}", removeItemTagHandler(store), http.MethodDelete)
+
+	handle(router, "/batch", batchHandler(store), http.MethodPost)
+
+	// The RPC endpoint dispatches back into this router.
+	handle(router, "/rpc", rpcHandler(router), http.MethodPost)
+
+	handle(router, "/admin/reload", adminReloadHandler(cfg), http.MethodPost)
+
+	return router
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// rpcMethod maps an RPC method onto the REST route that implements it.
+type rpcMethod struct {
+	httpMethod string
+	// route builds the request path (with query) from the params.
+	route func(params map[string]interface{}) string
+	// body selects what is sent as the request body; nil sends no body.
+	body func(params map[string]interface{}) interface{}
+}
+
+// stringParam returns params[name] as a string, or "" when absent.
+func stringParam(params map[string]interface{}, name string) string {
+	if v, ok := params[name]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// fixedRoute returns a route builder for a path without parameters.
+func fixedRoute(path string) func(map[string]interface{}) string {
+	return func(map[string]interface{}) string { return path }
+}
+
+// allParams sends the params unchanged as the request body.
+func allParams(params map[string]interface{}) interface{} { return params }
+
+// rpcMethods lists the operations available through POST /rpc.
+var rpcMethods = map[string]rpcMethod{
+	"getData": {httpMethod: http.MethodGet, route: fixedRoute("/data")},
+	"getItems": {httpMethod: http.MethodGet, route: func(p map[string]interface{}) string {
+		return "/data/items?tag=" + url.QueryEscape(stringParam(p, "tag"))
+	}},
+	"addItem":     {httpMethod: http.MethodPost, route: fixedRoute("/data/items"), body: allParams},
+	"deleteItems": {httpMethod: http.MethodDelete, route: fixedRoute("/data/items"), body: allParams},
+	"deleteItem": {httpMethod: http.MethodDelete, route: fixedRoute("/data/items"), body: func(p map[string]interface{}) interface{} {
+		return map[string]interface{}{"ids": []string{stringParam(p, "id")}}
+	}},
+	"splitItem":    {httpMethod: http.MethodPost, route: fixedRoute("/data/items/split"), body: allParams},
+	"combineItems": {httpMethod: http.MethodPost, route: fixedRoute("/data/items/combine"), body: allParams},
+	"addTag": {httpMethod: http.MethodPost, route: func(p map[string]interface{}) string {
+		return "/data/items/" + url.PathEscape(stringParam(p, "id")) + "/tags"
+	}, body: func(p map[string]interface{}) interface{} {
+		return map[string]interface{}{"tag": stringParam(p, "tag")}
+	}},
+	"removeTag": {httpMethod: http.MethodDelete, route: func(p map[string]interface{}) string {
+		return "/data/items/" + url.PathEscape(stringParam(p, "id")) + "/tags/" + url.PathEscape(stringParam(p, "tag"))
+	}},
+	"getSuggestions": {httpMethod: http.MethodGet, route: func(p map[string]interface{}) string {
+		if n := stringParam(p, "n"); n != "" {
+			return "/suggestions?n=" + url.QueryEscape(n)
+		}
+		return "/suggestions"
+	}},
+}
+
+// rpcError is the error member of an RPC response, carrying the status code
+// and error code the equivalent REST call would have returned.
+type rpcError struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// rpcResponse is the body returned by POST /rpc: either a result or an error.
+type rpcResponse struct {
+	Result interface{} `json:"result,omitempty"`
+	Error  *rpcError   `json:"error,omitempty"`
+}
+
+// dispatch runs one operation through the REST router and converts the
+// recorded response into an RPC response.
+func dispatch(api http.Handler, r *http.Request, method, path string, body interface{}) rpcResponse {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return rpcResponse{Error: &rpcError{Code: http.StatusBadRequest, Message: err.Error(), ErrorCode: codeBadRequest}}
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), method, path, bytes.NewReader(payload))
+	if err != nil {
+		return rpcResponse{Error: &rpcError{Code: http.StatusBadRequest, Message: err.Error(), ErrorCode: codeBadRequest}}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+
+	if rec.Code >= http.StatusBadRequest {
+		var apiErr apiError
+		if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr.Code == "" {
+			apiErr.Message = strings.TrimSpace(rec.Body.String())
+		}
+		return rpcResponse{Error: &rpcError{Code: rec.Code, Message: apiErr.Message, ErrorCode: apiErr.Code}}
+	}
+	var result interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		result = strings.TrimSpace(rec.Body.String())
+	}
+	return rpcResponse{Result: result}
+}
+
+// rpcHandler handles POST /rpc with a body of the form
+// {"method": "addItem", "params": {...}}. The call is dispatched to the REST
+// handler implementing the operation, so both surfaces share the same store
+// logic and validation. The response is always 200 with either {"result"}
+// or {"error": {"code", "message"}}, where code is the REST status.
+func rpcHandler(api http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var call struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&call); err != nil {
+			writeJSON(w, http.StatusOK, rpcResponse{Error: &rpcError{Code: http.StatusBadRequest, Message: "Invalid JSON format in request body: " + err.Error(), ErrorCode: codeInvalidJSON}})
+			return
+		}
+		m, ok := rpcMethods[call.Method]
+		if !ok {
+			writeJSON(w, http.StatusOK, rpcResponse{Error: &rpcError{Code: http.StatusNotFound, Message: fmt.Sprintf("Unknown method %q", call.Method), ErrorCode: codeNotFound}})
+			return
+		}
+		if call.Params == nil {
+			call.Params = map[string]interface{}{}
+		}
+
+		var body interface{}
+		if m.body != nil {
+			body = m.body(call.Params)
+		}
+		writeJSON(w, http.StatusOK, dispatch(api, r, m.httpMethod, m.route(call.Params), body))
+	}
+}
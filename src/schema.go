@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Document is the typed shape of the data file as written by the web client.
+// The store itself keeps the generic JSONData so unknown fields survive; the
+// typed form is only used where a request must be checked against the schema.
+// Document, Item and PendingEntry are the one list of the fields the server
+// knows: the strict decoders accept them and GET /schema describes them, so
+// a field a handler writes must be declared here.
+type Document struct {
+	Catalog             []Item         `json:"catalog"`
+	PendingList         []PendingEntry `json:"pendingList"`
+	UpdatedAt           string         `json:"updatedAt,omitempty" schema:"format=date-time,set by POST /data/touch"`
+	CompletedCategories []string       `json:"completedCategories,omitempty" schema:"categories marked as done; cleared when the pending list empties"`
+}
+
+// Item is a catalog entry: the blueprint of something that can be bought.
+// The schema tags describe the fields for GET /schema, which adds the rules
+// that depend on the configuration, see configuredSchema.
+type Item struct {
+	ID           string      `json:"id" schema:"generated when omitted on create"`
+	Name         string      `json:"name" schema:"required by POST /data/items and PATCH /data/items/{id}"`
+	OriginalName string      `json:"originalName,omitempty" schema:"name as sent before -normalize-names changed it"`
+	ImageURL     string      `json:"imageUrl,omitempty" schema:"format=uri"`
+	Category     string      `json:"category,omitempty" schema:"store section ordered by /settings/sections; Parent/Child nests categories"`
+	Quantity     float64     `json:"quantity,omitempty" schema:"amount in unit"`
+	Unit         string      `json:"unit,omitempty" schema:"such as kg or cup; GET /data/items can convert it to g or ml"`
+	Store        string      `json:"store,omitempty" schema:"shop the item is bought at, see GET /data?store"`
+	Aisle        interface{} `json:"aisle,omitempty" schema:"aisle number or name, see GET /data?aisle"`
+	Priority     interface{} `json:"priority,omitempty" schema:"a number or a label such as high"`
+	Tags         []string    `json:"tags,omitempty" schema:"lowercased and deduplicated"`
+	Color        string      `json:"color,omitempty" schema:"hex code (#rgb or #rrggbb) or a palette name"`
+	Archived     bool        `json:"archived,omitempty" schema:"set by the archive endpoints"`
+	ExpiresAt    string      `json:"expiresAt,omitempty" schema:"format=date-time,removed once passed; defaults to now + -item-ttl"`
+}
+
+// PendingEntry is an item that currently needs to be bought.
+type PendingEntry struct {
+	ItemID   string  `json:"itemId" schema:"required,references catalog id"`
+	Quantity float64 `json:"quantity" schema:"required"`
+	Checked  bool    `json:"checked,omitempty" schema:"true once the item is in the cart"`
+}
+
+// decodeJSON decodes a request body into v. With -strict-json, fields that
+// v does not declare are rejected instead of silently ignored, which helps
+// clients catch typos in field names. With -reject-duplicate-keys, repeated
+// keys are rejected as well.
+func decodeJSON(cfg *Config, r io.Reader, v interface{}) error {
+	if cfg.RejectDuplicateKeys {
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if err := checkDuplicateKeys(body); err != nil {
+			return err
+		}
+		r = bytes.NewReader(body)
+	}
+	dec := json.NewDecoder(r)
+	if cfg.StrictJSON {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// checkItemFields decodes a catalog item into the typed Item, rejecting
+// fields the item schema doesn't know. It is used in -strict-fields mode and
+// returns a *validationError naming the unknown field.
+func checkItemFields(item JSONData) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&Item{}); err != nil {
+		return &validationError{msg: strings.TrimPrefix(err.Error(), "json: ")}
+	}
+	return nil
+}
+
+// checkItemChanges checks the fields of patched that differ from item
+// against the typed Item, as decodeItem checks the body of a new item: the
+// values must have the schema's types and, in -strict-json mode, the fields
+// must be part of it. Fields left as they were are not checked again.
+func checkItemChanges(cfg *Config, item, patched JSONData) error {
+	changed := JSONData{}
+	for field, value := range patched {
+		if !reflect.DeepEqual(item[field], value) {
+			changed[field] = value
+		}
+	}
+	raw, err := json.Marshal(changed)
+	if err != nil {
+		return err
+	}
+	if err := decodeJSON(cfg, bytes.NewReader(raw), &Item{}); err != nil {
+		return &validationError{msg: strings.TrimPrefix(err.Error(), "json: ")}
+	}
+	return nil
+}
+
+// decodeItem decodes a single item from a request body. The item is kept as
+// generic JSON so fields the schema doesn't know survive, but it is checked
+// against the typed Item first (rejecting unknown fields in -strict-json
+// mode). With -canonicalize-values the item is canonicalized before that
+// check.
+func decodeItem(cfg *Config, r io.Reader) (JSONData, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CanonicalizeValues {
+		if body, err = canonicalizeItemBody(cfg, body); err != nil {
+			return nil, err
+		}
+	}
+	if err := decodeJSON(cfg, bytes.NewReader(body), &Item{}); err != nil {
+		return nil, err
+	}
+
+	var item JSONData
+	if err := json.Unmarshal(body, &item); err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, errors.New("item must be a JSON object")
+	}
+	return item, nil
+}
+
+// writeDecodeError answers a request whose body could not be decoded:
+// validation errors get 422, anything else is a malformed body.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	if errors.As(err, new(*validationError)) {
+		writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStrictModesAcceptCategory(t *testing.T) {
+	_, api := newTestAPI(t, "-strict-fields", "-strict-json")
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"name": "Leche", "category": "Dairy"}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "a", "name": "Queso", "category": "Dairy/Cheese"}], "pendingList": []}`, http.StatusOK)
+}
+
+// fetchDocument returns the body of GET /data.
+func fetchDocument(t *testing.T, api http.Handler) string {
+	t.Helper()
+	return mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK).Body.String()
+}
+
+func TestTouchedDocumentRoundTrips(t *testing.T) {
+	_, api := newTestAPI(t, "-strict-json")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"name": "Milk"}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPost, "/data/touch", "", http.StatusOK)
+
+	doc := fetchDocument(t, api)
+	if !strings.Contains(doc, `"updatedAt"`) {
+		t.Fatalf("GET /data after a touch = %s, want an updatedAt", doc)
+	}
+	mustDo(t, api, http.MethodPut, "/data", doc, http.StatusOK)
+}
+
+func TestCompletedCategoriesRoundTrip(t *testing.T) {
+	_, api := newTestAPI(t, "-strict-json")
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "milk", "name": "Milk", "category": "Dairy"}], "pendingList": [{"itemId": "milk", "quantity": 1}]}`, http.StatusOK)
+	mustDo(t, api, http.MethodPost, "/data/categories/Dairy/complete", "", http.StatusOK)
+
+	doc := fetchDocument(t, api)
+	if !strings.Contains(doc, `"completedCategories"`) {
+		t.Fatalf("GET /data after completing a category = %s, want completedCategories", doc)
+	}
+	mustDo(t, api, http.MethodPut, "/data", doc, http.StatusOK)
+}
+
+// schemaFields returns the names of the fields of a model in GET /schema.
+func schemaFields(fields []fieldSchema) map[string]bool {
+	names := map[string]bool{}
+	for _, field := range fields {
+		names[field.Name] = true
+	}
+	return names
+}
+
+// Every field the handlers write must be described by GET /schema, which
+// also means the strict decoders accept it.
+func TestSchemaDescribesWrittenFields(t *testing.T) {
+	_, api := newTestAPI(t, "-normalize-names", "-keep-original-name", "-item-ttl", "1h")
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [], "pendingList": []}`, http.StatusOK)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": " oat   milk ", "imageUrl": "https://example.com/milk.png",
+		"category": "Dairy", "quantity": 1, "unit": "l", "store": "Market", "aisle": 3, "priority": "high", "color": "blue"}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "old", "name": "Old"}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPost, "/data/items/milk/tags", `{"tag": "Vegan"}`, http.StatusOK)
+	mustDo(t, api, http.MethodPost, "/data/items/old/archive", "", http.StatusOK)
+	doc := fetchDocument(t, api)
+	doc = strings.Replace(doc, `"pendingList":[]`, `"pendingList":[{"itemId":"milk","quantity":2,"checked":true}]`, 1)
+	mustDo(t, api, http.MethodPut, "/data", doc, http.StatusOK)
+	mustDo(t, api, http.MethodPost, "/data/categories/Dairy/complete", "", http.StatusOK)
+	mustDo(t, api, http.MethodPost, "/data/touch", "", http.StatusOK)
+
+	var schema struct {
+		Document     []fieldSchema `json:"document"`
+		Item         []fieldSchema `json:"item"`
+		PendingEntry []fieldSchema `json:"pendingEntry"`
+	}
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/schema", "", http.StatusOK), &schema)
+	documentFields := schemaFields(schema.Document)
+	itemFields := schemaFields(schema.Item)
+	pendingFields := schemaFields(schema.PendingEntry)
+
+	var data struct {
+		Catalog     []JSONData `json:"catalog"`
+		PendingList []JSONData `json:"pendingList"`
+	}
+	rec := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK)
+	decodeBody(t, rec, &data)
+	var raw JSONData
+	decodeBody(t, rec, &raw)
+	if len(data.Catalog) != 2 || len(data.PendingList) != 1 {
+		t.Fatalf("document = %s, want two items and one pending entry", rec.Body)
+	}
+
+	check := func(model string, known map[string]bool, object JSONData) {
+		for field := range object {
+			if !known[field] {
+				t.Errorf("field %q is written to the %s but missing from GET /schema", field, model)
+			}
+		}
+	}
+	check("document", documentFields, raw)
+	for _, item := range data.Catalog {
+		check("item", itemFields, item)
+	}
+	for _, entry := range data.PendingList {
+		check("pendingEntry", pendingFields, entry)
+	}
+	written := map[string]bool{}
+	for _, item := range data.Catalog {
+		for field := range item {
+			written[field] = true
+		}
+	}
+	for field := range itemFields {
+		if !written[field] {
+			t.Errorf("no item has %s; the test should write every item field", field)
+		}
+	}
+}
+
+func TestStrictFields(t *testing.T) {
+	_, api := newTestAPI(t, "-strict-fields")
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk", "tags": ["dairy"], "quantity": 2, "unit": "l"}`, http.StatusCreated)
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodPost, "/data/items", `{"name": "Eggs", "colour": "white"}`},
+		{http.MethodPut, "/data", `{"catalog": [{"id": "eggs", "name": "Eggs", "colour": "white"}], "pendingList": []}`},
+		{http.MethodPatch, "/data/items/milk", `{"colour": "white"}`},
+	} {
+		rec := mustDo(t, api, tc.method, tc.path, tc.body, http.StatusUnprocessableEntity)
+		if !strings.Contains(rec.Body.String(), `\"colour\"`) {
+			t.Errorf("%s %s: body %s, want the unknown field named", tc.method, tc.path, rec.Body)
+		}
+	}
+
+	// Without the option unknown fields are kept.
+	_, lenient := newTestAPI(t)
+	mustDo(t, lenient, http.MethodPost, "/data/items", `{"name": "Eggs", "colour": "white"}`, http.StatusCreated)
+}
+
+// schemaField returns a field of a model in GET /schema.
+func schemaField(t *testing.T, fields []fieldSchema, name string) fieldSchema {
+	t.Helper()
+	for _, field := range fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	t.Fatalf("GET /schema has no field %s", name)
+	return fieldSchema{}
+}
+
+func TestSchemaFollowsConfig(t *testing.T) {
+	var schema struct {
+		Item         []fieldSchema `json:"item"`
+		PendingEntry []fieldSchema `json:"pendingEntry"`
+	}
+
+	// By default the server doesn't enforce these, so the schema doesn't
+	// promise them.
+	_, api := newTestAPI(t)
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/schema", "", http.StatusOK), &schema)
+	if name := schemaField(t, schema.Item, "name"); name.Required || name.Constraints != nil {
+		t.Errorf("default name schema = %+v, want no constraints", name)
+	}
+	if quantity := schemaField(t, schema.PendingEntry, "quantity"); quantity.Constraints != nil {
+		t.Errorf("default pending quantity schema = %+v, want no constraints", quantity)
+	}
+
+	_, api = newTestAPI(t, "-blank-names", "reject", "-max-string-length", "50", "-max-quantity", "99",
+		"-reject-negative-quantities", "-id-format", "^[a-z0-9-]+$", "-field-types", "aisle:number,notes:string")
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/schema", "", http.StatusOK), &schema)
+	name := schemaField(t, schema.Item, "name")
+	if !name.Required || name.Constraints["minLength"] != 1.0 || name.Constraints["maxLength"] != 50.0 {
+		t.Errorf("name schema = %+v, want required with 1 to 50 characters", name)
+	}
+	if id := schemaField(t, schema.Item, "id"); id.Constraints["pattern"] != "^[a-z0-9-]+$" {
+		t.Errorf("id schema = %+v, want the -id-format pattern", id)
+	}
+	for _, fields := range [][]fieldSchema{schema.Item, schema.PendingEntry} {
+		quantity := schemaField(t, fields, "quantity")
+		if quantity.Constraints["minimum"] != 0.0 || quantity.Constraints["maximum"] != 99.0 {
+			t.Errorf("quantity schema = %+v, want 0 to 99", quantity)
+		}
+	}
+	if tags := schemaField(t, schema.Item, "tags"); tags.Constraints["itemMaxLength"] != 50.0 {
+		t.Errorf("tags schema = %+v, want the string limit on each tag", tags)
+	}
+	if aisle := schemaField(t, schema.Item, "aisle"); aisle.Type != "number" {
+		t.Errorf("aisle type = %s, want number from -field-types", aisle.Type)
+	}
+	if notes := schemaField(t, schema.Item, "notes"); notes.Type != "string" || notes.Constraints["maxLength"] != 50.0 {
+		t.Errorf("notes schema = %+v, want a string of up to 50 characters", notes)
+	}
+}
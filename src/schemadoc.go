@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldSchema describes one field of a model for form rendering.
+type fieldSchema struct {
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	Items       string                 `json:"items,omitempty"`
+	Required    bool                   `json:"required"`
+	Constraints map[string]interface{} `json:"constraints,omitempty"`
+	Notes       []string               `json:"notes,omitempty"`
+}
+
+// describeModel builds field descriptions from a struct's json and schema
+// tags. A schema tag is a comma separated list of "required", key=value
+// constraints (numbers are reported as numbers) and free-form notes.
+func describeModel(model interface{}) []fieldSchema {
+	t := reflect.TypeOf(model)
+	fields := make([]fieldSchema, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		field := fieldSchema{Name: name, Type: jsonType(sf.Type)}
+		if sf.Type.Kind() == reflect.Slice {
+			field.Items = jsonType(sf.Type.Elem())
+		}
+		for _, rule := range strings.Split(sf.Tag.Get("schema"), ",") {
+			rule = strings.TrimSpace(rule)
+			key, value, isConstraint := strings.Cut(rule, "=")
+			switch {
+			case rule == "":
+			case rule == "required":
+				field.Required = true
+			case isConstraint:
+				if n, err := strconv.ParseFloat(value, 64); err == nil {
+					field.constrain(key, n)
+				} else {
+					field.constrain(key, value)
+				}
+			default:
+				field.Notes = append(field.Notes, rule)
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// constrain sets a constraint of the field.
+func (f *fieldSchema) constrain(key string, value interface{}) {
+	if f.Constraints == nil {
+		f.Constraints = map[string]interface{}{}
+	}
+	f.Constraints[key] = value
+}
+
+// configuredSchema is describeModel with the rules of the configured
+// validators added, so the schema only promises what the server enforces:
+// -max-string-length, -max-quantity, -reject-negative-quantities and, for
+// items, -blank-names, -id-format and -field-types.
+func configuredSchema(cfg *Config, model interface{}) []fieldSchema {
+	fields := describeModel(model)
+	if _, ok := model.(Item); ok {
+		fields = configuredItemFields(cfg, fields)
+	}
+	for i := range fields {
+		field := &fields[i]
+		if cfg.MaxStringLength > 0 {
+			switch {
+			case field.Type == "string":
+				field.constrain("maxLength", float64(cfg.MaxStringLength))
+			case field.Items == "string":
+				field.constrain("itemMaxLength", float64(cfg.MaxStringLength))
+			}
+		}
+		if field.Name == "quantity" {
+			if cfg.RejectNegativeQuantities {
+				field.constrain("minimum", 0.0)
+			}
+			if cfg.MaxQuantity > 0 {
+				field.constrain("maximum", cfg.MaxQuantity)
+			}
+		}
+	}
+	return fields
+}
+
+// configuredItemFields adds the item rules of -blank-names, -id-format and
+// -field-types to the described item fields. Fields typed by -field-types
+// that the Item model doesn't declare are appended.
+func configuredItemFields(cfg *Config, fields []fieldSchema) []fieldSchema {
+	field := func(name string) *fieldSchema {
+		for i := range fields {
+			if fields[i].Name == name {
+				return &fields[i]
+			}
+		}
+		return nil
+	}
+	if cfg.BlankNames != "allow" {
+		name := field("name")
+		name.Required = true
+		name.constrain("minLength", 1.0)
+	}
+	if cfg.IDFormat != nil {
+		field("id").constrain("pattern", cfg.IDFormat.String())
+	}
+
+	typed := make([]string, 0, len(cfg.FieldTypes))
+	for name := range cfg.FieldTypes {
+		typed = append(typed, name)
+	}
+	sort.Strings(typed)
+	for _, name := range typed {
+		typ := cfg.FieldTypes[name]
+		if typ == "bool" {
+			typ = "boolean"
+		}
+		known := field(name)
+		if known == nil {
+			fields = append(fields, fieldSchema{Name: name, Type: typ, Notes: []string{"typed by -field-types"}})
+			continue
+		}
+		known.Type = typ
+		if typ != "array" {
+			known.Items = ""
+		}
+	}
+	return fields
+}
+
+// jsonType names the JSON type a Go type is encoded as.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Interface:
+		// Fields such as aisle take more than one type; the schema notes
+		// say which.
+		return "any"
+	default:
+		return "object"
+	}
+}
+
+// schemaHandler handles GET /schema, describing the document, item and
+// pending entry models so clients can generate their forms from the
+// server's rules. The descriptions are built from the same types the strict
+// decoders check requests against (see decodeJSON and checkItemFields), so
+// a field is documented here exactly when -strict-json and -strict-fields
+// accept it, and their constraints from the configuration the validators
+// run with (see configuredSchema).
+func schemaHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"document":     configuredSchema(s.cfg, Document{}),
+			"item":         configuredSchema(s.cfg, Item{}),
+			"pendingEntry": configuredSchema(s.cfg, PendingEntry{}),
+			"strictFields": s.cfg.StrictFields,
+			"colorPalette": s.cfg.ColorPalette,
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+)
+
+// loadSections returns the category to aisle order mapping used to sort
+// items along the route through the store.
+func (s *Store) loadSections() (map[string]int, error) {
+	sections := map[string]int{}
+	if err := s.sections.load(&sections); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// sortByRoute orders items by the aisle of their category, so the list can
+// be walked through the store in one pass. Items whose category has no aisle
+// go last; items in the same aisle are ordered by -sort-tie-breaker.
+func sortByRoute(cfg *Config, items []JSONData, sections map[string]int) {
+	aisle := func(item JSONData) int {
+		category, _ := item["category"].(string)
+		if order, ok := sections[category]; ok {
+			return order
+		}
+		return math.MaxInt
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if a, b := aisle(items[i]), aisle(items[j]); a != b {
+			return a < b
+		}
+		return itemsTieBreak(cfg, items[i], items[j]) < 0
+	})
+}
+
+// sectionsHandler handles GET and PUT /settings/sections. The body is an
+// object mapping category names to their integer order in the store, e.g.
+// {"Fruta": 1, "Limpieza": 7}. PUT replaces the whole mapping.
+func sectionsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			sections, err := s.loadSections()
+			if err != nil {
+				log.Printf("Error in GET /settings/sections: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+				return
+			}
+			writeJSON(w, http.StatusOK, sections)
+
+		case http.MethodPut:
+			var raw map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&raw); err != nil || raw == nil {
+				writeError(w, http.StatusBadRequest, codeInvalidJSON, "Request body must be a JSON object")
+				return
+			}
+			sections := make(map[string]int, len(raw))
+			for category, value := range raw {
+				order, ok := value.(float64)
+				if !ok || order != math.Trunc(order) {
+					writeError(w, http.StatusUnprocessableEntity, codeValidationFailed, fmt.Sprintf("Order of section %q must be an integer", category))
+					return
+				}
+				sections[category] = int(order)
+			}
+			if err := s.sections.save(sections); err != nil {
+				log.Printf("Error in PUT /settings/sections: %v", err)
+				writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error: Failed to save sections")
+				return
+			}
+			writeJSON(w, http.StatusOK, sections)
+
+		default:
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+		}
+	}
+}
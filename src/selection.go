@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+)
+
+// fieldSelection is a parsed ?select= parameter: the selected fields mapped
+// to their nested selection, or nil when the whole value is selected.
+type fieldSelection map[string]fieldSelection
+
+// parseSelection parses a GraphQL-like field selection such as
+// "id,name,subItems{name,bought}".
+func parseSelection(input string) (fieldSelection, error) {
+	p := &selectionParser{input: input}
+	sel, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return sel, nil
+}
+
+type selectionParser struct {
+	input string
+	pos   int
+}
+
+func (p *selectionParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parseList parses "field (, field)*".
+func (p *selectionParser) parseList() (fieldSelection, error) {
+	sel := fieldSelection{}
+	for {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := sel[name]; dup {
+			return nil, fmt.Errorf("field %q selected twice", name)
+		}
+
+		var nested fieldSelection
+		p.skipSpaces()
+		if p.pos < len(p.input) && p.input[p.pos] == '{' {
+			p.pos++
+			if nested, err = p.parseList(); err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+			if p.pos >= len(p.input) || p.input[p.pos] != '}' {
+				return nil, fmt.Errorf("missing '}' after fields of %q", name)
+			}
+			p.pos++
+		}
+		sel[name] = nested
+
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ',' {
+			return sel, nil
+		}
+		p.pos++
+	}
+}
+
+// parseName parses a field name made of letters, digits, '_' and '-'.
+func (p *selectionParser) parseName() (string, error) {
+	p.skipSpaces()
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			break
+		}
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos >= len(p.input) {
+			return "", fmt.Errorf("expected a field name at end of selection")
+		}
+		return "", fmt.Errorf("expected a field name at position %d", p.pos)
+	}
+	return p.input[start:p.pos], nil
+}
+
+// project keeps only the selected fields of a value. Nested selections apply
+// to objects and to every object in an array; other values are kept whole.
+func (sel fieldSelection) project(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(sel))
+		for name, nested := range sel {
+			value, ok := v[name]
+			if !ok {
+				continue
+			}
+			if nested != nil {
+				value = nested.project(value)
+			}
+			out[name] = value
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = sel.project(elem)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timingPhases are the phases reported in the Server-Timing header, in
+// order, with the description shown by browser dev tools.
+var timingPhases = []struct{ name, desc string }{
+	{"lock", "store lock wait"},
+	{"read", "disk read"},
+	{"write", "disk write"},
+	{"serialize", "response serialization"},
+}
+
+// serverTimings accumulates how long a request spent in each phase. A nil
+// *serverTimings records nothing, so the store can be instrumented
+// unconditionally.
+type serverTimings struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases map[string]time.Duration
+}
+
+type serverTimingsKey struct{}
+
+// timingsFrom returns the timings of the request ctx belongs to, or nil when
+// -server-timing is off.
+func timingsFrom(ctx context.Context) *serverTimings {
+	t, _ := ctx.Value(serverTimingsKey{}).(*serverTimings)
+	return t
+}
+
+// timingsOf returns the timings of the response w writes, or nil when
+// -server-timing is off. It is used where only the writer is at hand.
+func timingsOf(w http.ResponseWriter) *serverTimings {
+	for {
+		switch rw := w.(type) {
+		case *timingWriter:
+			return rw.timings
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// since adds the time elapsed since start to phase. It is meant to be
+// deferred: defer t.since("lock", time.Now()).
+func (t *serverTimings) since(phase string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.phases[phase] += time.Since(start)
+}
+
+// header formats the timings as a Server-Timing value, with durations in
+// milliseconds, e.g. lock;desc="store lock wait";dur=0.004, total;dur=1.250.
+// Phases the request didn't go through are left out.
+func (t *serverTimings) header() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var metrics []string
+	for _, p := range timingPhases {
+		if d, ok := t.phases[p.name]; ok {
+			metrics = append(metrics, fmt.Sprintf("%s;desc=%q;dur=%s", p.name, p.desc, formatMillis(d)))
+		}
+	}
+	metrics = append(metrics, "total;dur="+formatMillis(time.Since(t.start)))
+	return strings.Join(metrics, ", ")
+}
+
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
+
+// timingWriter adds the Server-Timing header when the response header is
+// written, after the handler went through the store.
+type timingWriter struct {
+	http.ResponseWriter
+	timings     *serverTimings
+	wroteHeader bool
+}
+
+func (w *timingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", w.timings.header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, so
+// streaming handlers can still flush.
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// serverTimingMiddleware measures the phases of every API request and
+// reports them in a Server-Timing header, which browser dev tools show in
+// the network panel. It is only installed with -server-timing.
+func serverTimingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := &serverTimings{start: time.Now(), phases: map[string]time.Duration{}}
+		ctx := context.WithValue(r.Context(), serverTimingsKey{}, t)
+		next.ServeHTTP(&timingWriter{ResponseWriter: w, timings: t}, r.WithContext(ctx))
+	})
+}
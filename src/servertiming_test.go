@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// serverTimingMetric matches one metric of a Server-Timing header as this
+// server formats it: name, an optional quoted description and the duration
+// in milliseconds.
+var serverTimingMetric = regexp.MustCompile(`^[a-z]+(;desc="[^"]*")?;dur=\d+\.\d{3}$`)
+
+func TestServerTimingHeader(t *testing.T) {
+	_, api := newTestAPI(t, "-server-timing")
+
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		phases             []string
+	}{
+		{http.MethodGet, "/data", "", http.StatusOK, []string{"lock", "read", "serialize", "total"}},
+		{http.MethodPost, "/data/items", `{"name": "Milk"}`, http.StatusCreated, []string{"lock", "read", "write", "serialize", "total"}},
+	} {
+		rec := mustDo(t, api, tc.method, tc.path, tc.body, tc.status)
+		header := rec.Header().Get("Server-Timing")
+		var phases []string
+		for _, metric := range strings.Split(header, ", ") {
+			if !serverTimingMetric.MatchString(metric) {
+				t.Errorf("%s %s: metric %q of Server-Timing %q is not name;dur=N", tc.method, tc.path, metric, header)
+			}
+			name, _, _ := strings.Cut(metric, ";")
+			phases = append(phases, name)
+		}
+		if strings.Join(phases, ",") != strings.Join(tc.phases, ",") {
+			t.Errorf("%s %s: Server-Timing phases %v, want %v", tc.method, tc.path, phases, tc.phases)
+		}
+	}
+}
+
+func TestServerTimingOff(t *testing.T) {
+	_, api := newTestAPI(t)
+	if header := mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK).Header().Get("Server-Timing"); header != "" {
+		t.Fatalf("Server-Timing = %q without -server-timing, want none", header)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shopFilter selects pending entries by the store and aisle fields of
+// their item and by whether they are checked, for GET /data?store=&aisle=.
+type shopFilter struct {
+	store   string
+	aisle   string
+	checked *bool
+	sort    string
+}
+
+// parseShopFilter reads the store, aisle, checked and sort parameters of
+// GET /data. ok is false when none of the filters is set, in which case
+// the whole document is returned as usual.
+func parseShopFilter(q url.Values) (f shopFilter, ok bool, err error) {
+	f.store = strings.TrimSpace(q.Get("store"))
+	f.aisle = strings.TrimSpace(q.Get("aisle"))
+	if v := q.Get("checked"); v != "" {
+		checked, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, false, errors.New("parameter checked must be true or false")
+		}
+		f.checked = &checked
+	}
+	if !q.Has("store") && !q.Has("aisle") && f.checked == nil {
+		return f, false, nil
+	}
+	switch f.sort = q.Get("sort"); f.sort {
+	case "", "aisle":
+	default:
+		return f, false, errors.New(`parameter sort must be "aisle"`)
+	}
+	return f, true, nil
+}
+
+// fieldText returns a string or number field of an item as text, trimmed.
+func fieldText(item JSONData, field string) string {
+	switch v := item[field].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// matches reports whether a pending entry passes the filter. Stores match
+// ignoring case; aisles are compared as text, so ?aisle=3 matches both 3
+// and "3".
+func (f shopFilter) matches(view pendingView) bool {
+	if f.store != "" && !strings.EqualFold(fieldText(view.Item, "store"), f.store) {
+		return false
+	}
+	if f.aisle != "" && !strings.EqualFold(fieldText(view.Item, "aisle"), f.aisle) {
+		return false
+	}
+	return f.checked == nil || *f.checked == view.Checked
+}
+
+// aisleOrder returns the sort key of an item's aisle: numbered aisles in
+// numeric order, then named ones alphabetically, then items without one.
+func aisleOrder(item JSONData) (float64, string) {
+	aisle := fieldText(item, "aisle")
+	if aisle == "" {
+		return math.Inf(1), ""
+	}
+	if n, err := strconv.ParseFloat(aisle, 64); err == nil {
+		return n, ""
+	}
+	return math.MaxFloat64, strings.ToLower(aisle)
+}
+
+// filterPending returns the pending entries, joined with their item, that
+// pass the filter, in pending list order or, with sort=aisle, in aisle
+// order with ties broken by -sort-tie-breaker.
+func filterPending(cfg *Config, data JSONData, f shopFilter) []pendingView {
+	views := []pendingView{}
+	raw, _ := data[pendingKey].([]interface{})
+	for _, entry := range raw {
+		ref, _ := entry.(map[string]interface{})
+		itemID, _ := ref["itemId"].(string)
+		item, err := findItem(data, itemID)
+		if err != nil {
+			continue
+		}
+		view := pendingView{Item: item}
+		view.Quantity, _ = ref["quantity"].(float64)
+		view.Checked, _ = ref["checked"].(bool)
+		if f.matches(view) {
+			views = append(views, view)
+		}
+	}
+	if f.sort == "aisle" {
+		sort.SliceStable(views, func(i, j int) bool {
+			ni, si := aisleOrder(views[i].Item)
+			nj, sj := aisleOrder(views[j].Item)
+			if ni != nj {
+				return ni < nj
+			}
+			if si != sj {
+				return si < sj
+			}
+			return itemsTieBreak(cfg, views[i].Item, views[j].Item) < 0
+		})
+	}
+	return views
+}
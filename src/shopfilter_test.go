@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// shopDocument has items in two stores, with numbered and named aisles.
+const shopDocument = `{"catalog": [
+	{"id": "milk", "name": "Milk", "store": "Trader Joes", "aisle": 3},
+	{"id": "bread", "name": "Bread", "store": "trader joes", "aisle": "Bakery"},
+	{"id": "eggs", "name": "Eggs", "store": "Trader Joes", "aisle": "3"},
+	{"id": "soap", "name": "Soap", "store": "Target", "aisle": 1},
+	{"id": "salt", "name": "Salt", "store": "Trader Joes"}
+], "pendingList": [
+	{"itemId": "salt", "quantity": 1},
+	{"itemId": "bread", "quantity": 1},
+	{"itemId": "milk", "quantity": 2, "checked": true},
+	{"itemId": "soap", "quantity": 1},
+	{"itemId": "eggs", "quantity": 12}
+]}`
+
+// filteredIDs returns the item ids of a filtered GET /data, in order.
+func filteredIDs(t *testing.T, api http.Handler, query string) []string {
+	t.Helper()
+	var views []pendingView
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data?"+query, "", http.StatusOK), &views)
+	ids := []string{}
+	for _, view := range views {
+		id, _ := view.Item["id"].(string)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestShopFilter(t *testing.T) {
+	// Strict modes must accept the fields the filter reads.
+	_, api := newTestAPI(t, "-strict-fields", "-strict-json")
+	mustDo(t, api, http.MethodPut, "/data", shopDocument, http.StatusOK)
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"store=trader%20joes", []string{"salt", "bread", "milk", "eggs"}},
+		// Aisle 3 and "3" tie and are ordered by id.
+		{"store=Trader%20Joes&sort=aisle", []string{"eggs", "milk", "bread", "salt"}},
+		{"aisle=3", []string{"milk", "eggs"}},
+		{"store=Trader%20Joes&checked=false", []string{"salt", "bread", "eggs"}},
+		{"store=Whole%20Foods", []string{}},
+	} {
+		if got := filteredIDs(t, api, tc.query); !slices.Equal(got, tc.want) {
+			t.Errorf("GET /data?%s = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+
+	mustDo(t, api, http.MethodGet, "/data?store=x&sort=name", "", http.StatusBadRequest)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// sidecarFile is a small JSON file of its own, such as -sections-file or
+// -preferences-file, for state that doesn't belong in the document the web
+// client loads.
+type sidecarFile struct {
+	path string
+	mu   sync.RWMutex
+}
+
+// load decodes the file into v. A missing or empty file leaves v untouched.
+func (f *sidecarFile) load(v interface{}) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	content, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) || (err == nil && len(content) == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", f.path, err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("error unmarshaling %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// save replaces the file with v, atomically so a crash can't leave it torn.
+func (f *sidecarFile) save(v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %w", f.path, err)
+	}
+	if err := writeFileAtomic(f.path, content, 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// accentFolds maps accented letters common in Spanish (and their close
+// relatives) to their unaccented ASCII form.
+var accentFolds = map[rune]string{
+	'á': "a", 'à': "a", 'ä': "a", 'â': "a", 'ã': "a",
+	'é': "e", 'è': "e", 'ë': "e", 'ê': "e",
+	'í': "i", 'ì': "i", 'ï': "i", 'î': "i",
+	'ó': "o", 'ò': "o", 'ö': "o", 'ô': "o", 'õ': "o",
+	'ú': "u", 'ù': "u", 'ü': "u", 'û': "u",
+	'ñ': "n", 'ç': "c",
+}
+
+// foldAccents lowercases s and strips the accents listed in accentFolds.
+func foldAccents(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if folded, ok := accentFolds[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// slugify turns an item name into a readable key: "Papel Cocina" becomes
+// "papel-cocina" and "Jamón Ibérico" becomes "jamon-iberico". Anything other
+// than ASCII letters and digits separates words.
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range foldAccents(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// slugItemID derives an id for a new item from its name, appending a
+// numeric suffix ("leche-2", "leche-3", ...) when the slug is already taken
+// or reserved.
+func slugItemID(data JSONData, name string, reserved ...string) string {
+	base := slugify(name)
+	if base == "" {
+		base = "item"
+	}
+	id := base
+	for n := 2; ; n++ {
+		if _, err := findItem(data, id); errors.Is(err, errItemNotFound) && !slices.Contains(reserved, id) {
+			return id
+		}
+		id = base + "-" + strconv.Itoa(n)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	for name, want := range map[string]string{
+		"Leche":              "leche",
+		"Azúcar Moreno":      "azucar-moreno",
+		"  Piña  colada! ":   "pina-colada",
+		"Jamón ibérico 100g": "jamon-iberico-100g",
+		"Ñoquis":             "noquis",
+		"¿?":                 "",
+	} {
+		if got := slugify(name); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSlugItemIDCollisions(t *testing.T) {
+	_, api := newTestAPI(t, "-item-ids", "slug")
+
+	for _, want := range []string{"azucar", "azucar-2", "azucar-3"} {
+		var item JSONData
+		decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items", `{"name": "Azúcar"}`, http.StatusCreated), &item)
+		if item["id"] != want {
+			t.Errorf("id = %v, want %s", item["id"], want)
+		}
+	}
+
+	// A name without any letters or digits still gets an id.
+	var item JSONData
+	decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items", `{"name": "¿?"}`, http.StatusCreated), &item)
+	if item["id"] != "item" {
+		t.Errorf("id = %v, want item", item["id"])
+	}
+}
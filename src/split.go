@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// errIncompatibleUnits is returned when combining items whose quantities
+// can't be added up, such as litres and kilograms.
+var errIncompatibleUnits = errors.New("incompatible units")
+
+// splitItemHandler handles POST /data/items/split with a body of the form
+// {"id": "...", "delimiter": ","}. The item's name is split on the delimiter
+// and every non-empty part becomes a new item inheriting the remaining fields
+// (image, category, tags, ...). The original item is removed and any pending
+// list entry for it is replaced by entries for the new items. The new items
+// are normalized and validated like any new item and returned in the order
+// their parts appear in the name.
+func splitItemHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var body struct {
+			ID        string `json:"id"`
+			Delimiter string `json:"delimiter"`
+		}
+		if err := decodeJSON(s.cfg, r.Body, &body); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+		if body.ID == "" || body.Delimiter == "" {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Both id and delimiter are required")
+			return
+		}
+
+		var created []JSONData
+		err := s.update(r.Context(), func(data JSONData) error {
+			original, err := findItem(data, body.ID)
+			if err != nil {
+				return err
+			}
+
+			name, _ := original["name"].(string)
+			var parts []string
+			for _, part := range strings.Split(name, body.Delimiter) {
+				if part = strings.TrimSpace(part); part != "" {
+					parts = append(parts, part)
+				}
+			}
+			if len(parts) < 2 {
+				return &validationError{msg: "Splitting the item name must produce at least two parts"}
+			}
+
+			items := catalogItems(data)
+			result := make([]JSONData, 0, len(items)+len(parts)-1)
+			newIDs := make([]string, 0, len(parts))
+			for _, item := range items {
+				if itemID, _ := item["id"].(string); itemID != body.ID {
+					result = append(result, item)
+					continue
+				}
+				// Insert the new items where the original was.
+				for _, part := range parts {
+					split := copyItem(original)
+					if err := nameDerivedItem(s.cfg, split, part); err != nil {
+						return err
+					}
+					split["id"] = generateItemID(s.cfg, data, split["name"].(string), newIDs...)
+					if err := validateItem(s.cfg, split); err != nil {
+						return err
+					}
+					result = append(result, split)
+					created = append(created, split)
+					newIDs = append(newIDs, split["id"].(string))
+				}
+			}
+			setCatalog(data, result)
+			replacePendingRefs(data, body.ID, newIDs)
+			return nil
+		})
+		writeItemResult(w, r, created, err)
+	}
+}
+
+// combineItemsHandler handles POST /data/items/combine with a body of the
+// form {"ids": ["...", "..."], "separator": ", "}, the inverse of a split.
+// The items are replaced by a single new item, inserted where the first one
+// was, that inherits the first item's fields, joins the names with the
+// separator (", " by default) and carries the tags of all of them. Their
+// quantities are summed, as are those of the pending list entries for the
+// originals, which are merged into one entry. Quantities in different units
+// are converted to their metric base unit first (see combinedUnit); items
+// whose units can't be converted into each other are refused with 409. The
+// combined item is normalized and validated like any new item. All ids must
+// exist before anything is changed.
+func combineItemsHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		var body struct {
+			IDs       []string `json:"ids"`
+			Separator *string  `json:"separator"`
+		}
+		if err := decodeJSON(s.cfg, r.Body, &body); err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidJSON, "Invalid JSON format in request body: "+err.Error())
+			return
+		}
+		if len(body.IDs) < 2 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "At least two ids are required")
+			return
+		}
+		combine := make(map[string]bool, len(body.IDs))
+		for _, id := range body.IDs {
+			if combine[id] {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "Duplicate id "+id)
+				return
+			}
+			combine[id] = true
+		}
+		separator := ", "
+		if body.Separator != nil {
+			separator = *body.Separator
+		}
+
+		var combined JSONData
+		err := s.update(r.Context(), func(data JSONData) error {
+			originals := make([]JSONData, len(body.IDs))
+			for i, id := range body.IDs {
+				item, err := findItem(data, id)
+				if err != nil {
+					return err
+				}
+				originals[i] = item
+			}
+			unit, factors, err := combinedUnit(originals)
+			if err != nil {
+				return err
+			}
+
+			combined = copyItem(originals[0])
+			names := make([]string, len(originals))
+			var tags []string
+			var quantity float64
+			var hasQuantity bool
+			for i, item := range originals {
+				names[i], _ = item["name"].(string)
+				if q, ok := item["quantity"].(float64); ok {
+					quantity += q * factors[i]
+					hasQuantity = true
+				}
+				for _, tag := range itemTags(item) {
+					if !slices.Contains(tags, tag) {
+						tags = append(tags, tag)
+					}
+				}
+			}
+			if err := nameDerivedItem(s.cfg, combined, strings.Join(names, separator)); err != nil {
+				return err
+			}
+			combined["id"] = generateItemID(s.cfg, data, combined["name"].(string))
+			if len(tags) > 0 {
+				setItemTags(combined, tags)
+			}
+			if hasQuantity {
+				combined["quantity"] = roundQuantity(quantity)
+			}
+			if unit != "" {
+				combined["unit"] = unit
+			}
+			if err := validateItem(s.cfg, combined); err != nil {
+				return err
+			}
+
+			items := catalogItems(data)
+			result := make([]JSONData, 0, len(items)-len(originals)+1)
+			for _, item := range items {
+				itemID, _ := item["id"].(string)
+				if itemID == body.IDs[0] {
+					result = append(result, combined)
+				} else if !combine[itemID] {
+					result = append(result, item)
+				}
+			}
+			setCatalog(data, result)
+			conversions := make(map[string]float64, len(body.IDs))
+			for i, id := range body.IDs {
+				conversions[id] = factors[i]
+			}
+			combinePendingRefs(data, conversions, combined["id"].(string))
+			return nil
+		})
+		writeItemResult(w, r, combined, err)
+	}
+}
+
+// nameDerivedItem names an item made from existing ones by a split or a
+// combine, normalizing the name like that of any new item. The
+// originalName inherited from the source item no longer applies and is
+// dropped. Names that are blank once normalized are refused.
+func nameDerivedItem(cfg *Config, item JSONData, name string) error {
+	delete(item, "originalName")
+	item["name"] = name
+	normalizeItemName(cfg, item)
+	if hasBlankName(item) {
+		return &validationError{msg: "Item name is required"}
+	}
+	return nil
+}
+
+// combinedUnit returns the unit the quantities of items add up in, and the
+// factor converting each item's quantities to it. Items in the same unit, or
+// all without one, keep it. Otherwise every unit must be listed in
+// metricBaseUnits with the same base unit, which the sum is expressed in;
+// anything else is an errIncompatibleUnits.
+func combinedUnit(items []JSONData) (string, []float64, error) {
+	units := make([]string, len(items))
+	factors := make([]float64, len(items))
+	same := true
+	for i, item := range items {
+		units[i], _ = item["unit"].(string)
+		factors[i] = 1
+		same = same && strings.EqualFold(strings.TrimSpace(units[i]), strings.TrimSpace(units[0]))
+	}
+	if same {
+		return units[0], factors, nil
+	}
+
+	var base string
+	for i, unit := range units {
+		conversion, known := metricBaseUnits[strings.ToLower(strings.TrimSpace(unit))]
+		if !known || (base != "" && conversion.base != base) {
+			return "", nil, fmt.Errorf("%w: %v is in %q, which can't be added to %v in %q", errIncompatibleUnits, items[i]["id"], unit, items[0]["id"], units[0])
+		}
+		base = conversion.base
+		factors[i] = conversion.factor
+	}
+	return base, factors, nil
+}
+
+// combinePendingRefs replaces the pending list entries referencing any of
+// the items in factors with a single entry for newID, placed where the first
+// of them was, whose quantity is the sum of theirs, each converted by the
+// factor of its item.
+func combinePendingRefs(data JSONData, factors map[string]float64, newID string) {
+	raw, ok := data[pendingKey].([]interface{})
+	if !ok {
+		return
+	}
+	pending := make([]interface{}, 0, len(raw))
+	var merged map[string]interface{}
+	for _, entry := range raw {
+		ref, ok := entry.(map[string]interface{})
+		itemID, _ := ref["itemId"].(string)
+		factor, combined := factors[itemID]
+		if !ok || !combined {
+			pending = append(pending, entry)
+			continue
+		}
+		quantity, _ := ref["quantity"].(float64)
+		if merged == nil {
+			merged = copyJSON(ref).(map[string]interface{})
+			merged["itemId"] = newID
+			merged["quantity"] = roundQuantity(quantity * factor)
+			pending = append(pending, merged)
+			continue
+		}
+		merged["quantity"] = roundQuantity(merged["quantity"].(float64) + quantity*factor)
+	}
+	data[pendingKey] = pending
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCombineConvertsUnits(t *testing.T) {
+	_, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPut, "/data", `{
+		"catalog": [
+			{"id": "flour", "name": "Flour", "quantity": 1, "unit": "kg"},
+			{"id": "more-flour", "name": "More flour", "quantity": 250, "unit": "g"}
+		],
+		"pendingList": [{"itemId": "flour", "quantity": 2}, {"itemId": "more-flour", "quantity": 500}]
+	}`, http.StatusOK)
+
+	var combined JSONData
+	decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items/combine", `{"ids": ["flour", "more-flour"]}`, http.StatusOK), &combined)
+	if combined["quantity"] != 1250.0 || combined["unit"] != "g" {
+		t.Errorf("combined item = %v, want 1250 g", combined)
+	}
+	var doc Document
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK), &doc)
+	if len(doc.PendingList) != 1 || doc.PendingList[0].Quantity != 2500 {
+		t.Errorf("pending list = %+v, want one entry of 2500", doc.PendingList)
+	}
+}
+
+func TestCombineSameUnit(t *testing.T) {
+	_, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [
+		{"id": "a", "name": "Apples", "quantity": 2, "unit": "bag"},
+		{"id": "b", "name": "Pears", "quantity": 1, "unit": "Bag"}
+	]}`, http.StatusOK)
+
+	var combined JSONData
+	decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items/combine", `{"ids": ["a", "b"]}`, http.StatusOK), &combined)
+	if combined["quantity"] != 3.0 || combined["unit"] != "bag" {
+		t.Errorf("combined item = %v, want 3 bag", combined)
+	}
+}
+
+func TestCombineIncompatibleUnits(t *testing.T) {
+	_, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [
+		{"id": "milk", "name": "Milk", "quantity": 1, "unit": "l"},
+		{"id": "flour", "name": "Flour", "quantity": 1, "unit": "kg"},
+		{"id": "eggs", "name": "Eggs", "quantity": 6}
+	]}`, http.StatusOK)
+
+	for _, ids := range []string{`["milk", "flour"]`, `["flour", "eggs"]`} {
+		rec := mustDo(t, api, http.MethodPost, "/data/items/combine", `{"ids": `+ids+`}`, http.StatusConflict)
+		if !strings.Contains(rec.Body.String(), codeIncompatibleUnits) {
+			t.Errorf("combining %s: body %s, want %s", ids, rec.Body, codeIncompatibleUnits)
+		}
+	}
+	var doc Document
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data", "", http.StatusOK), &doc)
+	if len(doc.Catalog) != 3 {
+		t.Errorf("catalog has %d items after refused combines, want 3", len(doc.Catalog))
+	}
+}
+
+func TestSplitAndCombineNormalizeNames(t *testing.T) {
+	_, api := newTestAPI(t, "-normalize-names", "-name-case", "title")
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "mix", "name": "oat  milk,  rye bread "}], "pendingList": []}`, http.StatusOK)
+
+	var split []JSONData
+	decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items/split", `{"id": "mix", "delimiter": ","}`, http.StatusOK), &split)
+	if len(split) != 2 || split[0]["name"] != "Oat Milk" || split[1]["name"] != "Rye Bread" {
+		t.Fatalf("split items = %v, want normalized names", split)
+	}
+
+	var combined JSONData
+	body := `{"ids": ["` + split[0]["id"].(string) + `", "` + split[1]["id"].(string) + `"], "separator": " and "}`
+	decodeBody(t, mustDo(t, api, http.MethodPost, "/data/items/combine", body, http.StatusOK), &combined)
+	if combined["name"] != "Oat Milk And Rye Bread" {
+		t.Errorf("combined name = %q, want it normalized", combined["name"])
+	}
+}
+
+func TestSplitValidatesNewItems(t *testing.T) {
+	_, api := newTestAPI(t, "-item-ids", "slug", "-id-format", "^[a-z-]+$")
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "mix", "name": "Milk, 7up"}], "pendingList": []}`, http.StatusOK)
+
+	rec := mustDo(t, api, http.MethodPost, "/data/items/split", `{"id": "mix", "delimiter": ","}`, http.StatusUnprocessableEntity)
+	if !strings.Contains(rec.Body.String(), "does not match the required format") {
+		t.Errorf("split: body %s, want the id format named", rec.Body)
+	}
+	storedItem(t, api, "mix")
+}
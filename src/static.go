@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+)
+
+// staticHandler serves the website directory, adding the headers browsers
+// expect before they allow the app to be installed as a PWA: the web app
+// manifest needs its own media type, the service worker must always be
+// revalidated so updates roll out, and the favicon can be cached for long.
+//
+// Only GET and HEAD are served. http.FileServer answers HEAD with the same
+// Content-Length, Content-Type and caching headers as GET but without a
+// body, so clients can probe asset sizes cheaply.
+//
+// The Content-Security-Policy only applies here: the API serves JSON, which
+// browsers never render as a page.
+func staticHandler(cfg *Config, dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if cfg.SecurityHeaders && cfg.ContentSecurityPolicy != "" {
+			w.Header().Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+		}
+		switch r.URL.Path {
+		case cfg.ManifestPath:
+			w.Header().Set("Content-Type", "application/manifest+json")
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+		case cfg.ServiceWorkerPath:
+			w.Header().Set("Cache-Control", "no-cache")
+		case cfg.FaviconPath:
+			w.Header().Set("Cache-Control", "public, max-age=604800")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
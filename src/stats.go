@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxActivityDays bounds the window of GET /stats/activity.
+const maxActivityDays = 366
+
+// activityDay counts what happened to the list on one UTC day.
+type activityDay struct {
+	Date    string `json:"date"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Bought  int    `json:"bought"`
+}
+
+// between returns the retained changes recorded at or after from, and
+// whether they are all of them: changes since from may already be pruned
+// when the oldest retained one is more recent and isn't the first ever.
+func (c *ChangeLog) between(from time.Time) ([]change, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var changes []change
+	for _, ch := range c.changes {
+		if !ch.Time.Before(from) {
+			changes = append(changes, ch)
+		}
+	}
+	complete := len(c.changes) == 0 && c.cursor == 0 ||
+		len(c.changes) > 0 && (c.changes[0].Cursor == 1 || c.changes[0].Time.Before(from))
+	return changes, complete
+}
+
+// parseWindowDays parses a window given in days, such as "7d".
+func parseWindowDays(window string) (int, bool) {
+	days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+	if err != nil || !strings.HasSuffix(window, "d") || days < 1 || days > maxActivityDays {
+		return 0, false
+	}
+	return days, true
+}
+
+// countActivity adds a change to the counts of its day. Catalog items that
+// appear or disappear are added or removed; a pending entry that leaves the
+// list while its item stays in the catalog was bought, as in boughtItems.
+func countActivity(day *activityDay, d documentDiff) {
+	removed := map[string]bool{}
+	for _, key := range d.Removed {
+		removed[key] = true
+	}
+	for _, key := range d.Added {
+		if strings.HasPrefix(key, catalogKey+"/") {
+			day.Added++
+		}
+	}
+	for _, key := range d.Removed {
+		if strings.HasPrefix(key, catalogKey+"/") {
+			day.Removed++
+		} else if id, ok := strings.CutPrefix(key, pendingKey+"/"); ok && !removed[catalogKey+"/"+id] {
+			day.Bought++
+		}
+	}
+}
+
+// activityHandler handles GET /stats/activity?window=7d, returning per UTC
+// day, oldest first, how many items were added to and removed from the
+// catalog and how many were bought, computed from the changes feed. Every
+// day of the window is listed, with zero counts when nothing happened. When
+// the feed no longer retains the start of the window, complete is false and
+// the first days may be undercounted.
+func activityHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		days := 7
+		if v := r.URL.Query().Get("window"); v != "" {
+			parsed, ok := parseWindowDays(v)
+			if !ok {
+				writeError(w, http.StatusBadRequest, codeInvalidParameter, "Parameter window must be a number of days between 1 and "+strconv.Itoa(maxActivityDays)+", such as 7d")
+				return
+			}
+			days = parsed
+		}
+
+		now := time.Now().UTC()
+		from := time.Date(now.Year(), now.Month(), now.Day()-days+1, 0, 0, 0, 0, time.UTC)
+		series := make([]activityDay, days)
+		index := map[string]*activityDay{}
+		for i := range series {
+			series[i].Date = from.AddDate(0, 0, i).Format(time.DateOnly)
+			index[series[i].Date] = &series[i]
+		}
+
+		changes, complete := s.changes.between(from)
+		for _, ch := range changes {
+			if day, ok := index[ch.Time.UTC().Format(time.DateOnly)]; ok {
+				countActivity(day, ch.Diff)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"window":   strconv.Itoa(days) + "d",
+			"complete": complete,
+			"days":     series,
+		})
+	}
+}
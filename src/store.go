@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// errReadOnly is returned for mutations while the store is in degraded
+// read-only mode.
+var errReadOnly = errors.New("store is temporarily read-only after write failures")
+
+// errDiskFull is returned for mutations that failed, or would fail, because
+// the disk holding the data file is full. The data file keeps its previous
+// content.
+var errDiskFull = errors.New("the server's disk is full, free up space there and retry; your previous list is kept")
+
+// errUnchanged is returned by update callbacks that made no modification,
+// so the data file doesn't need to be rewritten.
+var errUnchanged = errors.New("data unchanged")
+
+// errWriteQueueFull is returned for mutations while a write is in progress
+// and the queue of writes waiting for it is full.
+var errWriteQueueFull = errors.New("too many concurrent writes, please retry")
+
+// writeQueueRetryAfter is the Retry-After, in seconds, of writes rejected
+// with errWriteQueueFull. The queue drains at disk speed, so a short wait
+// is usually enough.
+const writeQueueRetryAfter = 1
+
+// JSONData is a type alias for a generic JSON object structure.
+type JSONData map[string]interface{}
+
+// Store holds the application state, including the file path and a mutex
+// for concurrent access control to the file.
+type Store struct {
+	filepath string
+	cfg      *Config
+	// RWMutex allows many readers or one writer at a time.
+	mu sync.RWMutex
+
+	// writeFile persists the serialized data. It defaults to replaceFile,
+	// syncing as set by -fsync, and is a field so the retry behavior can be
+	// exercised with a failing writer.
+	writeFile func(name string, data []byte, perm os.FileMode) error
+
+	// writeQueue holds the write in progress and the ones waiting for s.mu,
+	// up to -write-queue of them. It is nil when the queue is unbounded.
+	writeQueue chan struct{}
+	// writesRejected counts the writes turned away with errWriteQueueFull.
+	writesRejected atomic.Int64
+
+	// mem, when set, holds the document in memory instead of the data
+	// file. It is used by the transaction store of a POST /batch.
+	mem JSONData
+
+	// purchases counts how often items were ticked off the pending list.
+	purchases *PurchaseLog
+	// changes is the feed of recent writes served by GET /data/changes.
+	changes *ChangeLog
+	// events pushes every change to the subscribers of GET /data/events.
+	events eventBroker
+	// demo tracks the -demo-reset schedule.
+	demo demoState
+	// dataCache holds the serialized GET /data response with -data-cache-ttl.
+	dataCache dataCache
+	// defaults holds field values for new items; nil without -defaults-file.
+	defaults *sidecarFile
+	// sections maps categories to their aisle order in the store.
+	sections *sidecarFile
+	// preferences holds the UI preferences of the web client.
+	preferences *sidecarFile
+
+	// Degraded read-only state, entered when writes keep failing. It has
+	// its own lock so health checks never wait on a slow disk write.
+	healthMu       sync.Mutex
+	readOnly       bool
+	readOnlySince  time.Time
+	lastWriteError string
+	diskFull       bool
+
+	// Cached result of the write check done by GET /health.
+	writeCheckMu  sync.Mutex
+	writeCheckAt  time.Time
+	writeCheckErr error
+}
+
+// dataDirMaxBackoff caps the interval between checks of waitForDataDir.
+const dataDirMaxBackoff = 5 * time.Second
+
+// waitForDataDir waits up to timeout for the directory holding the data
+// file to exist, checking again with exponential backoff. Network volumes
+// in containers are sometimes mounted only after the server starts; without
+// waiting, creating the data file would fail and the server exit.
+func waitForDataDir(dataFile string, timeout time.Duration) error {
+	dir := filepath.Dir(dataFile)
+	deadline := time.Now().Add(timeout)
+	backoff := 250 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("Data directory %s is available", dir)
+			}
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return err
+		}
+		wait := min(backoff, remaining)
+		log.Printf("Data directory %s is not available yet (attempt %d), retrying in %s: %v", dir, attempt, wait.Round(time.Millisecond), err)
+		time.Sleep(wait)
+		backoff = min(backoff*2, dataDirMaxBackoff)
+	}
+}
+
+// NewStore initializes a new Store and ensures the data file exists.
+func NewStore(cfg *Config) *Store {
+	if err := waitForDataDir(cfg.DataFile, cfg.DataDirWait); err != nil {
+		log.Fatalf("Data directory unavailable: %v", err)
+	}
+
+	s := &Store{filepath: cfg.DataFile, cfg: cfg}
+	s.writeFile = func(name string, data []byte, perm os.FileMode) error {
+		return replaceFile(name, data, perm, cfg.Fsync)
+	}
+
+	purchases, err := NewPurchaseLog(cfg.PurchasesFile)
+	if err != nil {
+		log.Fatalf("Failed to load purchase history: %v", err)
+	}
+	s.purchases = purchases
+	changes, err := NewChangeLog(cfg.ChangesFile, cfg.MaxChanges)
+	if err != nil {
+		log.Fatalf("Failed to load changes feed: %v", err)
+	}
+	s.changes = changes
+	s.sections = &sidecarFile{path: cfg.SectionsFile}
+	s.preferences = &sidecarFile{path: cfg.PreferencesFile}
+	if cfg.DefaultsFile != "" {
+		s.defaults = &sidecarFile{path: cfg.DefaultsFile}
+		if _, err := s.loadItemDefaults(); err != nil {
+			log.Fatalf("Failed to load item defaults: %v", err)
+		}
+	}
+	if cfg.WriteQueue > 0 {
+		s.writeQueue = make(chan struct{}, 1+cfg.WriteQueue)
+	}
+
+	if cfg.MirrorFile != "" {
+		s.restoreFromMirror()
+	}
+
+	// Attempt to create the file if it doesn't exist, initializing it with an empty JSON object.
+	if _, err := os.Stat(s.filepath); os.IsNotExist(err) {
+		log.Printf("Data file %s not found, creating a new empty one.", s.filepath)
+		if err := s.saveDataFile(context.Background(), JSONData{}); err != nil {
+			log.Fatalf("Failed to initialize data file: %v", err)
+		}
+	}
+	return s
+}
+
+// readDataFile reads the JSON data from the file, locking the store for reading.
+func (s *Store) readDataFile(ctx context.Context) (JSONData, error) {
+	t := timingsFrom(ctx)
+	start := time.Now()
+	s.mu.RLock()         // Acquire read lock
+	defer s.mu.RUnlock() // Release read lock when function returns
+	t.since("lock", start)
+
+	return s.timedRead(t)
+}
+
+// timedRead is read, recorded as the "read" phase of the request timings.
+func (s *Store) timedRead(t *serverTimings) (JSONData, error) {
+	defer t.since("read", time.Now())
+	return s.read()
+}
+
+// timedWrite is write, recorded as the "write" phase of the request timings.
+func (s *Store) timedWrite(t *serverTimings, data JSONData) error {
+	defer t.since("write", time.Now())
+	return s.write(data)
+}
+
+// lockForWrite takes a place in the write queue and s.mu, recording the wait
+// for both as the "lock" phase of the request timings. The returned func unlocks.
+func (s *Store) lockForWrite(t *serverTimings) (func(), error) {
+	start := time.Now()
+	release, err := s.acquireWrite()
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	t.since("lock", start)
+	return func() {
+		s.mu.Unlock()
+		release()
+	}, nil
+}
+
+// read loads and parses the data file. Callers must hold s.mu.
+func (s *Store) read() (JSONData, error) {
+	if s.mem != nil {
+		return copyDocument(s.mem), nil
+	}
+	content, err := os.ReadFile(s.filepath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+
+	// Handle empty file case. Whitespace such as the trailing newline of
+	// -trailing-newline, or one added by an editor, is ignored.
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return JSONData{}, nil
+	}
+
+	var data JSONData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("error unmarshaling JSON: %w", err)
+	}
+	return data, nil
+}
+
+// acquireWrite takes a place in the write queue. Writes are serialized by
+// s.mu anyway, so the queue bounds how many wait for it: when it is full
+// the write fails with errWriteQueueFull instead, giving a slow disk
+// backpressure instead of an ever growing pile of blocked requests. The
+// returned func gives the place back.
+func (s *Store) acquireWrite() (func(), error) {
+	if s.writeQueue == nil {
+		return func() {}, nil
+	}
+	select {
+	case s.writeQueue <- struct{}{}:
+	default:
+		s.writesRejected.Add(1)
+		return nil, errWriteQueueFull
+	}
+	return func() { <-s.writeQueue }, nil
+}
+
+// writeQueueStats is the state of the write queue reported by GET /status.
+type writeQueueStats struct {
+	InProgress int   `json:"inProgress"`
+	Waiting    int   `json:"waiting"`
+	MaxWaiting int   `json:"maxWaiting"`
+	Rejected   int64 `json:"rejected"`
+}
+
+// writeQueueState returns whether a write is in progress and how many are
+// waiting, or nil when the queue is unbounded.
+func (s *Store) writeQueueState() *writeQueueStats {
+	if s.writeQueue == nil {
+		return nil
+	}
+	queued := len(s.writeQueue)
+	return &writeQueueStats{
+		InProgress: min(queued, 1),
+		Waiting:    max(queued-1, 0),
+		MaxWaiting: cap(s.writeQueue) - 1,
+		Rejected:   s.writesRejected.Load(),
+	}
+}
+
+// saveDataFile writes the JSON data to the file, locking the store for writing.
+// This function overwrites the entire file content.
+func (s *Store) saveDataFile(ctx context.Context, data JSONData) error {
+	t := timingsFrom(ctx)
+	unlock, err := s.lockForWrite(t)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return s.timedWrite(t, data)
+}
+
+// replace overwrites the document with newData and returns the previous
+// version. A previous version that can't be read, such as a corrupt file, is
+// returned as nil instead of blocking the overwrite that would fix it.
+func (s *Store) replace(ctx context.Context, newData JSONData) (JSONData, error) {
+	t := timingsFrom(ctx)
+	unlock, err := s.lockForWrite(t)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	oldData, err := s.timedRead(t)
+	if err != nil {
+		log.Printf("Overwriting unreadable data file %s: %v", s.filepath, err)
+		oldData = nil
+	}
+	resetCompletedCategories(oldData, newData)
+	if err := checkWrite(s.cfg, oldData, newData); err != nil {
+		return oldData, err
+	}
+	if err := s.timedWrite(t, newData); err != nil {
+		return oldData, err
+	}
+	s.recordChange(oldData, newData)
+	return oldData, nil
+}
+
+// update performs a read-modify-write cycle while holding the write lock, so
+// concurrent requests cannot interleave between reading and saving the data.
+// If fn returns an error, or the result fails checkWrite, the data file is
+// left untouched; returning errUnchanged skips the write without reporting a
+// failure.
+//
+// The lock wait, read and write are recorded in the Server-Timing of the
+// request ctx.
+func (s *Store) update(ctx context.Context, fn func(data JSONData) error) error {
+	t := timingsFrom(ctx)
+	unlock, err := s.lockForWrite(t)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := s.timedRead(t)
+	if err != nil {
+		return err
+	}
+	before := copyDocument(data)
+	if err := fn(data); errors.Is(err, errUnchanged) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	resetCompletedCategories(before, data)
+	if err := checkWrite(s.cfg, before, data); err != nil {
+		return err
+	}
+	if err := s.timedWrite(t, data); err != nil {
+		return err
+	}
+	s.recordChange(before, data)
+	return nil
+}
+
+// checkWrite enforces the rules that apply to what a write changes rather
+// than to the document as a whole, whichever endpoint made the change.
+func checkWrite(cfg *Config, before, after JSONData) error {
+	if err := checkCompositeUnique(cfg, before, after); err != nil {
+		return err
+	}
+	if err := checkQuantities(cfg, before, after); err != nil {
+		return err
+	}
+	return checkStringLengths(cfg, before, after)
+}
+
+// write serializes the data and overwrites the file. Callers must hold s.mu.
+func (s *Store) write(data JSONData) error {
+	if s.mem != nil {
+		s.mem = copyDocument(data)
+		return nil
+	}
+	if readOnly, diskFull := s.writeState(); diskFull {
+		return errDiskFull
+	} else if readOnly {
+		return errReadOnly
+	}
+
+	s.dataCache.invalidate()
+
+	// The file is byte-stable for a given document, so it diffs cleanly
+	// when kept in git: the document is generic JSON, and encoding/json
+	// sorts the keys of maps at every depth, formats numbers canonically
+	// and indents consistently. Only array order carries over from the
+	// writer, since it is meaningful (e.g. the catalog order).
+	jsonData, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JSON: %w", err)
+	}
+	if s.cfg.TrailingNewline {
+		jsonData = append(jsonData, '\n')
+	}
+
+	// Write the data to the file, overwriting existing content. Transient
+	// I/O errors are retried with exponential backoff before giving up.
+	live := s.cfg.Live()
+	backoff := live.WriteRetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = s.writeFile(s.filepath, jsonData, 0644)
+		if err == nil {
+			break
+		}
+		if attempt >= live.WriteRetries || !isRetryableWriteError(err) {
+			s.enterReadOnly(err)
+			if isDiskFullError(err) {
+				log.Printf("ALERT: disk full, could not save %s; the previous version is kept: %v", s.filepath, err)
+				return fmt.Errorf("%w: %v", errDiskFull, err)
+			}
+			return fmt.Errorf("error writing to file: %w", err)
+		}
+		log.Printf("Write to %s failed (attempt %d/%d), retrying in %s: %v",
+			s.filepath, attempt+1, live.WriteRetries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	log.Printf("Successfully saved data to %s", s.filepath)
+
+	// The mirror is best effort: the primary already holds the change.
+	if s.cfg.MirrorFile != "" {
+		if err := s.writeFile(s.cfg.MirrorFile, jsonData, 0644); err != nil {
+			log.Printf("Error writing mirror file %s: %v", s.cfg.MirrorFile, err)
+		}
+	}
+	return nil
+}
+
+// restoreFromMirror replaces a missing or unreadable data file with the
+// mirror, if the mirror itself is valid. It runs once at startup.
+func (s *Store) restoreFromMirror() {
+	if _, err := s.read(); err == nil {
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Data file %s is unreadable: %v", s.filepath, err)
+	}
+
+	content, err := os.ReadFile(s.cfg.MirrorFile)
+	if err != nil {
+		log.Printf("Cannot restore from mirror file: %v", err)
+		return
+	}
+	var data JSONData
+	if err := json.Unmarshal(content, &data); err != nil {
+		log.Printf("Cannot restore from mirror file %s: %v", s.cfg.MirrorFile, err)
+		return
+	}
+	if err := s.writeFile(s.filepath, content, 0644); err != nil {
+		log.Fatalf("Failed to restore data file from mirror: %v", err)
+	}
+	log.Printf("ALERT: restored data file %s from mirror %s", s.filepath, s.cfg.MirrorFile)
+}
+
+// isReadOnly reports whether the store is in degraded read-only mode.
+func (s *Store) isReadOnly() bool {
+	readOnly, _ := s.writeState()
+	return readOnly
+}
+
+// writeState reports whether the store is in degraded read-only mode and
+// whether it entered it because the disk is full.
+func (s *Store) writeState() (readOnly, diskFull bool) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	return s.readOnly, s.readOnly && s.diskFull
+}
+
+// enterReadOnly switches the store to degraded read-only mode after a failed
+// write, so a failing disk isn't hammered by every mutation. A background
+// probe periodically tries a small write and lifts the mode once it succeeds.
+func (s *Store) enterReadOnly(cause error) {
+	if !s.cfg.DegradeOnWriteFailure {
+		return
+	}
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	s.lastWriteError = cause.Error()
+	s.diskFull = isDiskFullError(cause)
+	if s.readOnly {
+		return
+	}
+	s.readOnly = true
+	s.readOnlySince = time.Now()
+	log.Printf("ALERT: writes to %s are failing, entering read-only mode: %v", s.filepath, cause)
+	go s.probeWrites()
+}
+
+// probeWrites retries a probe write next to the data file until one
+// succeeds, then leaves read-only mode.
+func (s *Store) probeWrites() {
+	probePath := s.filepath + ".probe"
+	for {
+		time.Sleep(s.cfg.ReadOnlyProbeInterval)
+		if err := s.writeFile(probePath, []byte("ok"), 0644); err != nil {
+			log.Printf("Probe write to %s failed, staying read-only: %v", probePath, err)
+			continue
+		}
+		os.Remove(probePath)
+
+		s.healthMu.Lock()
+		s.readOnly = false
+		s.healthMu.Unlock()
+		log.Printf("Probe write to %s succeeded, leaving read-only mode", probePath)
+		return
+	}
+}
+
+// isDiskFullError reports whether a write failed for lack of space, either
+// on the device or within the user's quota.
+func isDiskFullError(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames it
+// over name once it is complete and synced. A failed write, such as on a full
+// disk, leaves the previous content of name intact instead of a truncated
+// file.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	return replaceFile(name, data, perm, true)
+}
+
+// replaceFile is writeFileAtomic with the syncs made optional. With fsync the
+// content reaches the disk before the rename and the rename itself is synced
+// through the directory, so after a crash name holds either the old or the
+// new content. Without it both may still sit in the OS cache: a crash can
+// lose the last writes or, on some filesystems, leave an empty file.
+func replaceFile(name string, data []byte, perm os.FileMode, fsync bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if fsync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), name)
+	if errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) {
+		// The data file is a mount point of its own, as when a container
+		// bind-mounts just the file; it can only be overwritten in place.
+		return writeFileInPlace(name, data, perm, fsync)
+	}
+	if err != nil || !fsync {
+		return err
+	}
+	return syncDir(filepath.Dir(name))
+}
+
+// writeFileInPlace overwrites name with data, syncing it when fsync is set.
+func writeFileInPlace(name string, data []byte, perm os.FileMode, fsync bool) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if fsync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// syncDir flushes the directory entries of dir, making a rename within it
+// durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// isRetryableWriteError reports whether a write failure is likely transient,
+// as is common on network filesystems. Errors such as permission denied will
+// not go away by retrying and are reported immediately.
+func isRetryableWriteError(err error) bool {
+	if errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	for _, errno := range []syscall.Errno{
+		syscall.EIO, syscall.EAGAIN, syscall.EINTR, syscall.EBUSY,
+		syscall.ESTALE, syscall.ETIMEDOUT,
+	} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
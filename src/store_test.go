@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// savedBytes writes the document given as JSON through a fresh store and
+// returns the content of its data file.
+func savedBytes(t *testing.T, doc string) []byte {
+	t.Helper()
+	s := newTestStore(t)
+	var data JSONData
+	if err := json.Unmarshal([]byte(doc), &data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveDataFile(context.Background(), data); err != nil {
+		t.Fatalf("saveDataFile: %v", err)
+	}
+	content, err := os.ReadFile(s.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// The data file must come out byte for byte the same for the same document,
+// whatever the key order it was sent with, so it diffs cleanly in git.
+func TestDataFileIsByteStable(t *testing.T) {
+	first := savedBytes(t, `{"pendingList": [{"quantity": 1.50, "itemId": "b"}], "catalog": [{"name": "Bread", "id": "b", "tags": ["x"]}, {"id": "a", "name": "Apple"}]}`)
+	second := savedBytes(t, `{"catalog": [{"tags": ["x"], "id": "b", "name": "Bread"}, {"name": "Apple", "id": "a"}], "pendingList": [{"itemId": "b", "quantity": 1.5}]}`)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("data files differ:\n%s\n%s", first, second)
+	}
+	for i := 0; i < 5; i++ {
+		if again := savedBytes(t, string(first)); !bytes.Equal(first, again) {
+			t.Fatalf("rewriting the data file changed it:\n%s\n%s", first, again)
+		}
+	}
+	if !bytes.HasSuffix(first, []byte("}\n")) {
+		t.Errorf("data file ends with %q, want a single trailing newline", first[len(first)-2:])
+	}
+}
+
+func TestWriteQueueFull(t *testing.T) {
+	s, api := newTestAPI(t, "-write-queue", "1")
+
+	// Hold the write in progress, so the next write has to wait for it.
+	unlock, err := s.lockForWrite(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiting := make(chan *httptest.ResponseRecorder)
+	go func() {
+		waiting <- do(api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`)
+	}()
+	for s.writeQueueState().Waiting < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full, so another write is turned away right away.
+	rec := do(api, http.MethodPost, "/data/items", `{"id": "eggs", "name": "Eggs"}`)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("status %d with Retry-After %q, want 503 with 1; body: %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), codeWriteQueueFull) {
+		t.Errorf("body %s, want %s", rec.Body, codeWriteQueueFull)
+	}
+	if state := s.writeQueueState(); state.Rejected != 1 {
+		t.Errorf("rejected = %d, want 1", state.Rejected)
+	}
+
+	// The waiting write goes through once the one in progress is done.
+	unlock()
+	if rec := <-waiting; rec.Code != http.StatusCreated {
+		t.Fatalf("queued write: status %d, want 201; body: %s", rec.Code, rec.Body)
+	}
+}
+
+// failingWrites makes the first len(errs) writes of s fail with errs, in
+// order, and returns the number of write attempts made so far.
+func failingWrites(s *Store, errs ...error) func() int {
+	attempts := 0
+	write := s.writeFile
+	s.writeFile = func(name string, data []byte, perm os.FileMode) error {
+		attempts++
+		if attempts <= len(errs) {
+			return &fs.PathError{Op: "write", Path: name, Err: errs[attempts-1]}
+		}
+		return write(name, data, perm)
+	}
+	return func() int { return attempts }
+}
+
+func TestWriteRetriesTransientErrors(t *testing.T) {
+	s := newTestStore(t, "-write-retry-backoff", "1ms", "-degrade-on-write-failure=false")
+	attempts := failingWrites(s, syscall.EIO)
+
+	if err := s.saveDataFile(context.Background(), JSONData{"catalog": []interface{}{}}); err != nil {
+		t.Fatalf("saveDataFile: %v", err)
+	}
+	if attempts() != 2 {
+		t.Errorf("%d write attempts, want 2", attempts())
+	}
+}
+
+func TestWriteDoesNotRetryPermanentErrors(t *testing.T) {
+	s := newTestStore(t, "-write-retry-backoff", "1ms", "-degrade-on-write-failure=false")
+	attempts := failingWrites(s, syscall.EACCES)
+
+	if err := s.saveDataFile(context.Background(), JSONData{"catalog": []interface{}{}}); err == nil {
+		t.Fatal("saveDataFile succeeded, want the permission error")
+	}
+	if attempts() != 1 {
+		t.Errorf("%d write attempts, want 1", attempts())
+	}
+}
+
+func TestWriteGivesUpAfterRetries(t *testing.T) {
+	s := newTestStore(t, "-write-retries", "2", "-write-retry-backoff", "1ms", "-degrade-on-write-failure=false")
+	attempts := failingWrites(s, syscall.EIO, syscall.EIO, syscall.EIO, syscall.EIO)
+
+	if err := s.saveDataFile(context.Background(), JSONData{"catalog": []interface{}{}}); err == nil {
+		t.Fatal("saveDataFile succeeded, want the I/O error")
+	}
+	if attempts() != 3 {
+		t.Errorf("%d write attempts, want 3", attempts())
+	}
+}
+
+func TestDiskFull(t *testing.T) {
+	s, api := newTestAPI(t)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+	before, err := os.ReadFile(s.filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := failingWrites(s, syscall.ENOSPC)
+
+	rec := mustDo(t, api, http.MethodPost, "/data/items", `{"id": "eggs", "name": "Eggs"}`, http.StatusInsufficientStorage)
+	if !strings.Contains(rec.Body.String(), codeStorageFull) {
+		t.Errorf("body %s, want %s", rec.Body, codeStorageFull)
+	}
+	if attempts() != 1 {
+		t.Errorf("%d write attempts, want 1: a full disk isn't retried", attempts())
+	}
+	if after, err := os.ReadFile(s.filepath); err != nil || !bytes.Equal(before, after) {
+		t.Errorf("data file changed after a failed write:\n%s\n%s", before, after)
+	}
+
+	// Until the disk has room again, writes fail the same way at once.
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "eggs", "name": "Eggs"}`, http.StatusInsufficientStorage)
+	if attempts() != 1 {
+		t.Errorf("%d write attempts, want no more while the disk is full", attempts())
+	}
+}
+
+func TestMirrorFollowsPrimary(t *testing.T) {
+	mirror := filepath.Join(t.TempDir(), "mirror.json")
+	s, api := newTestAPI(t, "-mirror-file", mirror)
+
+	for _, body := range []string{`{"id": "milk", "name": "Milk"}`, `{"id": "eggs", "name": "Eggs"}`} {
+		mustDo(t, api, http.MethodPost, "/data/items", body, http.StatusCreated)
+		primary, err := os.ReadFile(s.filepath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copied, err := os.ReadFile(mirror)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(primary, copied) {
+			t.Fatalf("mirror differs from the data file:\n%s\n%s", copied, primary)
+		}
+	}
+
+	// A corrupt data file is restored from the mirror on the next start.
+	if err := os.WriteFile(s.filepath, []byte(`{"catalog": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restarted := NewStore(s.cfg)
+	data, err := restarted.readDataFile(context.Background())
+	if err != nil {
+		t.Fatalf("reading the restored data file: %v", err)
+	}
+	if items := catalogItems(data); len(items) != 2 {
+		t.Errorf("restored catalog = %v, want both items", items)
+	}
+}
+
+func TestMirrorFailureDoesNotFailWrites(t *testing.T) {
+	mirror := filepath.Join(t.TempDir(), "missing", "mirror.json")
+	_, api := newTestAPI(t, "-mirror-file", mirror)
+
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+	storedItem(t, api, "milk")
+}
+
+func TestReplaceFileWithAndWithoutFsync(t *testing.T) {
+	for _, fsync := range []bool{true, false} {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "data.json")
+		for _, content := range []string{`{"catalog": []}`, `{"catalog": [{"id": "a"}]}`} {
+			if err := replaceFile(name, []byte(content), 0644, fsync); err != nil {
+				t.Fatalf("fsync %v: replaceFile: %v", fsync, err)
+			}
+			if got, err := os.ReadFile(name); err != nil || string(got) != content {
+				t.Fatalf("fsync %v: file holds %q (%v), want %q", fsync, got, err, content)
+			}
+		}
+		// The temporary file is renamed or removed, never left behind.
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("fsync %v: directory holds %d files, want only the data file", fsync, len(entries))
+		}
+	}
+}
+
+func TestFsyncFlag(t *testing.T) {
+	for _, arg := range []string{"-fsync", "-fsync=false"} {
+		s, api := newTestAPI(t, arg)
+		if s.cfg.Fsync != (arg == "-fsync") {
+			t.Fatalf("%s: Fsync = %v", arg, s.cfg.Fsync)
+		}
+		mustDo(t, api, http.MethodPost, "/data/items", `{"id": "milk", "name": "Milk"}`, http.StatusCreated)
+		content, err := os.ReadFile(s.filepath)
+		if err != nil || !strings.Contains(string(content), `"milk"`) {
+			t.Errorf("%s: data file = %s (%v), want the new item", arg, content, err)
+		}
+	}
+}
+
+func TestTrailingNewline(t *testing.T) {
+	for _, tc := range []struct {
+		arg  string
+		want string
+	}{
+		{"-trailing-newline", "}\n"},
+		{"-trailing-newline=false", "}"},
+	} {
+		s := newTestStore(t, tc.arg)
+		for i := 0; i < 2; i++ {
+			if err := s.saveDataFile(context.Background(), JSONData{"catalog": []interface{}{}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		content, err := os.ReadFile(s.filepath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(string(content), tc.want) || strings.HasSuffix(string(content), "\n\n") {
+			t.Errorf("%s: data file ends with %q, want %q", tc.arg, content[max(len(content)-3, 0):], tc.want)
+		}
+	}
+}
+
+func TestReadIgnoresSurroundingWhitespace(t *testing.T) {
+	s := newTestStore(t)
+	for _, content := range []string{"{\"catalog\": []}\n\n", "\n  {\"catalog\": []}", "  \n"} {
+		if err := os.WriteFile(s.filepath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.readDataFile(context.Background()); err != nil {
+			t.Errorf("reading %q: %v", content, err)
+		}
+	}
+}
+
+func TestWaitForDataDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "volume")
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		os.Mkdir(dir, 0755)
+	}()
+	if err := waitForDataDir(filepath.Join(dir, "data.json"), 10*time.Second); err != nil {
+		t.Fatalf("waiting for a directory that appears: %v", err)
+	}
+
+	// A directory that never appears fails once the wait is over.
+	start := time.Now()
+	if err := waitForDataDir(filepath.Join(t.TempDir(), "never", "data.json"), 300*time.Millisecond); err == nil {
+		t.Fatal("waiting for a missing directory succeeded")
+	}
+	if waited := time.Since(start); waited < 300*time.Millisecond || waited > 2*time.Second {
+		t.Errorf("gave up after %s, want about 300ms", waited)
+	}
+
+	// A file in place of the directory is never going to work.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForDataDir(filepath.Join(file, "data.json"), 10*time.Second); err == nil {
+		t.Error("a file as the data directory was accepted")
+	}
+}
+
+func TestNewStoreWaitsForDataDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "volume")
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		os.Mkdir(dir, 0755)
+	}()
+	s := newTestStore(t, "-data-file", filepath.Join(dir, "data.json"), "-data-dir-wait", "10s")
+	if _, err := os.Stat(s.filepath); err != nil {
+		t.Errorf("data file not created once the directory appeared: %v", err)
+	}
+}
+
+func TestWriteQueueDepthUnderLoad(t *testing.T) {
+	const queue, burst = 4, 20
+	s, api := newTestAPI(t, "-write-queue", strconv.Itoa(queue))
+
+	unlock, err := s.lockForWrite(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make(chan int, burst)
+	for i := 0; i < burst; i++ {
+		go func() {
+			codes <- do(api, http.MethodPost, "/data/items", fmt.Sprintf(`{"name": "Item %d"}`, i)).Code
+		}()
+	}
+
+	// Every write beyond the queue is rejected while the others wait.
+	rejected := 0
+	for rejected < burst-queue {
+		if code := <-codes; code != http.StatusServiceUnavailable {
+			t.Fatalf("write finished with status %d while the queue was held, want 503", code)
+		}
+		rejected++
+	}
+	want := writeQueueStats{InProgress: 1, Waiting: queue, MaxWaiting: queue, Rejected: burst - queue}
+	if got := *s.writeQueueState(); got != want {
+		t.Errorf("write queue = %+v, want %+v", got, want)
+	}
+
+	// The queued writes all go through once the lock is released.
+	unlock()
+	for i := 0; i < queue; i++ {
+		if code := <-codes; code != http.StatusCreated {
+			t.Errorf("queued write: status %d, want 201", code)
+		}
+	}
+
+	// GET /status reports the drained queue and the rejections.
+	var status struct {
+		WriteQueue writeQueueStats `json:"writeQueue"`
+	}
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/status", "", http.StatusOK), &status)
+	want = writeQueueStats{MaxWaiting: queue, Rejected: burst - queue}
+	if status.WriteQueue != want {
+		t.Errorf("GET /status writeQueue = %+v, want %+v", status.WriteQueue, want)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// streamFlushEvery is how many items are written between flushes when
+// streaming a listing.
+const streamFlushEvery = 100
+
+// streamJSONArray writes items as a JSON array one element at a time,
+// flushing periodically so clients can start parsing large listings before
+// the whole response is produced. The items are a snapshot taken after the
+// store's read lock was released, so no lock is held while writing.
+//
+// Once the first byte is sent the status can no longer change; if encoding
+// or writing fails mid-stream the error is logged and the response is cut
+// short, leaving the client with an incomplete (invalid) array rather than a
+// silently truncated but valid one.
+func streamJSONArray(w http.ResponseWriter, r *http.Request, items []JSONData) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write([]byte("[")); err != nil {
+		log.Printf("Error streaming %s: %v", r.URL.Path, err)
+		return
+	}
+	for i, item := range items {
+		chunk, err := json.Marshal(item)
+		if err != nil {
+			log.Printf("Error streaming %s: item %d: %v", r.URL.Path, i, err)
+			return
+		}
+		if i > 0 {
+			chunk = append([]byte(","), chunk...)
+		}
+		if _, err := w.Write(chunk); err != nil {
+			log.Printf("Error streaming %s: %v", r.URL.Path, err)
+			return
+		}
+		if (i+1)%streamFlushEvery == 0 {
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				log.Printf("Error flushing %s: %v", r.URL.Path, err)
+				return
+			}
+		}
+	}
+	if _, err := w.Write([]byte("]\n")); err != nil {
+		log.Printf("Error streaming %s: %v", r.URL.Path, err)
+	}
+}
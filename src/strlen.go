@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// checkStringLengths rejects writes that put a string longer than
+// -max-string-length characters anywhere in the document, naming its path,
+// e.g. catalog[2].notes. Strings that were already at the same path before
+// the write are left alone, so lowering the limit doesn't block writes to a
+// list that already exceeds it.
+func checkStringLengths(cfg *Config, before, after JSONData) error {
+	if cfg.MaxStringLength <= 0 {
+		return nil
+	}
+	if path, length := longString(cfg.MaxStringLength, map[string]interface{}(after), map[string]interface{}(before), ""); path != "" {
+		return &validationError{msg: fmt.Sprintf("%s: %d characters exceed the maximum of %d", path, length, cfg.MaxStringLength)}
+	}
+	return nil
+}
+
+// longString returns the path and length of the first string in value
+// longer than max characters that differs from the value at the same path in
+// old, or "" when there is none. Object keys are visited in sorted order.
+func longString(max int, value, old interface{}, path string) (string, int) {
+	switch v := value.(type) {
+	case string:
+		if previous, ok := old.(string); ok && previous == v {
+			return "", 0
+		}
+		if length := utf8.RuneCountInString(v); length > max {
+			return path, length
+		}
+	case map[string]interface{}:
+		oldObj, _ := old.(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if found, length := longString(max, v[key], oldObj[key], child); found != "" {
+				return found, length
+			}
+		}
+	case []interface{}:
+		oldArr, _ := old.([]interface{})
+		for i, elem := range v {
+			var oldElem interface{}
+			if i < len(oldArr) {
+				oldElem = oldArr[i]
+			}
+			if found, length := longString(max, elem, oldElem, path+"["+strconv.Itoa(i)+"]"); found != "" {
+				return found, length
+			}
+		}
+	}
+	return "", 0
+}
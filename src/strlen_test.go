@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaxStringLength(t *testing.T) {
+	_, api := newTestAPI(t, "-max-string-length", "10")
+
+	// Characters are counted, not bytes.
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "pina", "name": "Piña dulce"}`, http.StatusCreated)
+
+	for _, tc := range []struct{ method, path, body, want string }{
+		{http.MethodPost, "/data/items", `{"id": "milk", "name": "Leche entera"}`, "catalog[1].name: 12 characters exceed the maximum of 10"},
+		{http.MethodPut, "/data", `{"catalog": [{"id": "a", "name": "A"}, {"id": "b", "name": "B", "details": {"notes": ["ok", "far too long"]}}]}`,
+			"catalog[1].details.notes[1]: 12 characters exceed the maximum of 10"},
+		{http.MethodPut, "/data", `{"catalog": [], "pendingList": [], "owner": {"name": "Nuria Fernández"}}`, "owner.name: 15 characters exceed the maximum of 10"},
+		{http.MethodPatch, "/data/items/pina", `{"tags": ["fruta", "tropical fruit"]}`, "tags[1]: 14 characters exceed the maximum of 10"},
+	} {
+		rec := mustDo(t, api, tc.method, tc.path, tc.body, http.StatusUnprocessableEntity)
+		if !strings.Contains(rec.Body.String(), tc.want) {
+			t.Errorf("%s %s: body %s, want %q", tc.method, tc.path, rec.Body, tc.want)
+		}
+	}
+}
+
+func TestMaxStringLengthSkipsUnchangedStrings(t *testing.T) {
+	cfg := newTestConfig(t, "-max-string-length", "10")
+	var before JSONData
+	if err := json.Unmarshal([]byte(`{"catalog": [{"id": "a", "name": "An old and long name"}]}`), &before); err != nil {
+		t.Fatal(err)
+	}
+
+	after := copyDocument(before)
+	setCatalog(after, append(catalogItems(after), JSONData{"id": "b", "name": "Short"}))
+	if err := checkStringLengths(cfg, before, after); err != nil {
+		t.Errorf("adding a short item next to an old long name: %v", err)
+	}
+}
+
+func TestNoStringLimitByDefault(t *testing.T) {
+	_, api := newTestAPI(t)
+	image := "data:image/png;base64," + strings.Repeat("A", 10000)
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "a", "name": "A", "imageUrl": "`+image+`"}], "pendingList": []}`, http.StatusOK)
+}
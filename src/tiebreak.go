@@ -0,0 +1,28 @@
+package main
+
+import "strings"
+
+// itemTieBreakers are the values accepted by -sort-tie-breaker.
+var itemTieBreakers = []string{"id", "name", "catalog"}
+
+// itemsTieBreak orders two items that the primary sort key considers
+// equal, by -sort-tie-breaker: "id" and "name" compare those fields (names
+// case-insensitively, then by id), "catalog" keeps their order in the
+// catalog. It returns a negative number when a goes first, positive when b
+// does and zero when the order should be kept.
+func itemsTieBreak(cfg *Config, a, b JSONData) int {
+	idA, _ := a["id"].(string)
+	idB, _ := b["id"].(string)
+	switch cfg.SortTieBreaker {
+	case "name":
+		nameA, _ := a["name"].(string)
+		nameB, _ := b["name"].(string)
+		if c := strings.Compare(strings.ToLower(nameA), strings.ToLower(nameB)); c != 0 {
+			return c
+		}
+		return strings.Compare(idA, idB)
+	case "id":
+		return strings.Compare(idA, idB)
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// updatedAtKey is the top-level field of the document POST /data/touch sets.
+const updatedAtKey = "updatedAt"
+
+// touchHandler handles POST /data/touch, which marks the list as still in
+// use without changing its items: it sets the document's updatedAt to the
+// current time, which, like any other change, is recorded in the changes
+// feed under a new version. The response holds both.
+func touchHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		err := s.update(r.Context(), func(data JSONData) error {
+			data[updatedAtKey] = now
+			return nil
+		})
+		if err != nil {
+			writeStoreError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			updatedAtKey: now,
+			"version":    s.changes.latest(),
+		})
+	}
+}
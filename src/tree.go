@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// categoryNode is one level of the category tree returned by GET /data/tree.
+// Completed is set on categories marked as walked during the current trip.
+type categoryNode struct {
+	Name      string          `json:"name"`
+	Path      string          `json:"path"`
+	Completed bool            `json:"completed,omitempty"`
+	Items     []JSONData      `json:"items"`
+	Children  []*categoryNode `json:"children"`
+}
+
+// child returns the subcategory with the given name, creating it if needed.
+func (n *categoryNode) child(name string) *categoryNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	path := name
+	if n.Path != "" {
+		path = n.Path + "/" + name
+	}
+	c := &categoryNode{Name: name, Path: path, Items: []JSONData{}, Children: []*categoryNode{}}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+// categorySegments splits a slash-delimited category path such as
+// "Food/Dairy/Cheese" into its segments. Segments are trimmed and empty ones
+// are dropped, so "Food//Dairy/" and " Food / Dairy" both mean Food/Dairy.
+func categorySegments(category string) []string {
+	var segments []string
+	for _, segment := range strings.Split(category, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// buildCategoryTree nests items under their category path. Items without a
+// (usable) category stay on the root node. Categories keep the order in
+// which they first appear in the catalog.
+func buildCategoryTree(items []JSONData) *categoryNode {
+	root := &categoryNode{Items: []JSONData{}, Children: []*categoryNode{}}
+	for _, item := range items {
+		category, _ := item["category"].(string)
+		node := root
+		for _, segment := range categorySegments(category) {
+			node = node.child(segment)
+		}
+		node.Items = append(node.Items, item)
+	}
+	return root
+}
+
+// markCompleted flags the nodes of the tree whose category is completed.
+func markCompleted(n *categoryNode, completed map[string]bool) {
+	n.Completed = n.Path != "" && completed[n.Path]
+	for _, c := range n.Children {
+		markCompleted(c, completed)
+	}
+}
+
+// treeHandler handles GET /data/tree, returning the active catalog items
+// nested by their slash-delimited category path, for collapsible views.
+func treeHandler(s *Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		data, err := s.readDataFile(r.Context())
+		if err != nil {
+			log.Printf("Error in GET /data/tree: %v", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "Internal Server Error")
+			return
+		}
+		tree := buildCategoryTree(activeItems(data))
+		markCompleted(tree, completedCategories(data))
+		writeJSONWithETag(s.cfg, w, r, tree)
+	}
+}
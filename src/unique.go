@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// duplicateItemError is returned for writes that would leave two catalog
+// items with the same -unique-by fields. Existing is the item already
+// holding those values.
+type duplicateItemError struct {
+	fields   []string
+	existing JSONData
+}
+
+func (e *duplicateItemError) Error() string {
+	id, _ := e.existing["id"].(string)
+	return fmt.Sprintf("an item with the same %s already exists: %s", strings.Join(e.fields, ", "), id)
+}
+
+// compositeKey returns the values of fields in item as one index key.
+// Strings are compared case-insensitively and without surrounding spaces,
+// so "Sugar" and "sugar " collide; a missing field counts as empty.
+func compositeKey(fields []string, item JSONData) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		switch value := item[field].(type) {
+		case nil:
+		case string:
+			parts[i] = strings.ToLower(strings.TrimSpace(value))
+		default:
+			raw, _ := json.Marshal(value)
+			parts[i] = string(raw)
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// checkCompositeUnique indexes the catalog of after by the -unique-by fields
+// and rejects duplicates the write introduced: a pair where at least one item
+// is new or changed since before. Duplicates already present are left alone,
+// so enabling the check doesn't block every write to an existing list.
+func checkCompositeUnique(cfg *Config, before, after JSONData) error {
+	if len(cfg.UniqueBy) == 0 {
+		return nil
+	}
+	previous := map[string]JSONData{}
+	for _, item := range catalogItems(before) {
+		if id, ok := item["id"].(string); ok {
+			previous[id] = item
+		}
+	}
+	written := func(item JSONData) bool {
+		id, _ := item["id"].(string)
+		old, ok := previous[id]
+		return !ok || !reflect.DeepEqual(old, item)
+	}
+
+	index := map[string]JSONData{}
+	for _, item := range catalogItems(after) {
+		key := compositeKey(cfg.UniqueBy, item)
+		other, dup := index[key]
+		if !dup {
+			index[key] = item
+			continue
+		}
+		switch {
+		case written(item):
+			return &duplicateItemError{fields: cfg.UniqueBy, existing: other}
+		case written(other):
+			return &duplicateItemError{fields: cfg.UniqueBy, existing: item}
+		}
+	}
+	return nil
+}
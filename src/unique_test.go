@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// conflictOf returns the id of the item a DUPLICATE_ITEM response names.
+func conflictOf(t *testing.T, body []byte) interface{} {
+	t.Helper()
+	var dup struct {
+		Code     string   `json:"code"`
+		Conflict JSONData `json:"conflict"`
+	}
+	if err := json.Unmarshal(body, &dup); err != nil {
+		t.Fatal(err)
+	}
+	if dup.Code != codeDuplicateItem {
+		t.Errorf("error code %q, want %s", dup.Code, codeDuplicateItem)
+	}
+	return dup.Conflict["id"]
+}
+
+func TestUniqueByCompositeKey(t *testing.T) {
+	_, api := newTestAPI(t, "-unique-by", "name,unit")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "sugar-kg", "name": "Sugar", "unit": "kg"}`, http.StatusCreated)
+
+	// The same name in another unit, or without one, is a different item.
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "sugar-g", "name": "Sugar", "unit": "g"}`, http.StatusCreated)
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "sugar", "name": "Sugar"}`, http.StatusCreated)
+
+	// Case and surrounding spaces don't make a new item.
+	rec := mustDo(t, api, http.MethodPost, "/data/items", `{"id": "sugar-2", "name": " sugar", "unit": "KG"}`, http.StatusConflict)
+	if id := conflictOf(t, rec.Body.Bytes()); id != "sugar-kg" {
+		t.Errorf("conflict = %v, want sugar-kg", id)
+	}
+
+	// Changing an item into a duplicate is rejected as well.
+	rec = mustDo(t, api, http.MethodPatch, "/data/items/sugar-g", `{"unit": "kg"}`, http.StatusConflict)
+	if id := conflictOf(t, rec.Body.Bytes()); id != "sugar-kg" {
+		t.Errorf("conflict = %v, want sugar-kg", id)
+	}
+	mustDo(t, api, http.MethodPut, "/data", `{"catalog": [{"id": "a", "name": "Salt", "unit": "g"}, {"id": "b", "name": "salt", "unit": "g"}]}`, http.StatusConflict)
+}
+
+func TestUniqueByKeepsExistingDuplicates(t *testing.T) {
+	cfg := newTestConfig(t, "-unique-by", "name,unit")
+	before := JSONData{catalogKey: []interface{}{
+		map[string]interface{}{"id": "a", "name": "Salt", "unit": "g"},
+		map[string]interface{}{"id": "b", "name": "Salt", "unit": "g"},
+	}}
+	after := copyDocument(before)
+	setCatalog(after, append(catalogItems(after), JSONData{"id": "c", "name": "Pepper"}))
+
+	if err := checkCompositeUnique(cfg, before, after); err != nil {
+		t.Errorf("adding an unrelated item to a list with duplicates: %v", err)
+	}
+}
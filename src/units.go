@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// unitConversion converts a unit to its metric base unit.
+type unitConversion struct {
+	base   string
+	factor float64
+}
+
+// metricBaseUnits maps the common cooking units (and their usual spellings)
+// to grams or millilitres.
+var metricBaseUnits = map[string]unitConversion{
+	"mg": {"g", 0.001},
+	"g":  {"g", 1},
+	"kg": {"g", 1000},
+	"oz": {"g", 28.349523125},
+	"lb": {"g", 453.59237},
+
+	"ml":    {"ml", 1},
+	"cl":    {"ml", 10},
+	"dl":    {"ml", 100},
+	"l":     {"ml", 1000},
+	"tsp":   {"ml", 4.92892159375},
+	"tbsp":  {"ml", 14.78676478125},
+	"fl oz": {"ml", 29.5735295625},
+	"cup":   {"ml", 236.5882365},
+	"pt":    {"ml", 473.176473},
+	"qt":    {"ml", 946.352946},
+	"gal":   {"ml", 3785.411784},
+}
+
+// toMetricBase rewrites an item's quantity and unit in the metric base unit
+// (grams or millilitres). Items without a numeric quantity or with a unit
+// missing from the table are left untouched. Converted quantities are
+// rounded with roundQuantity.
+func toMetricBase(item JSONData) {
+	quantity, ok := item["quantity"].(float64)
+	unit, _ := item["unit"].(string)
+	conversion, known := metricBaseUnits[strings.ToLower(strings.TrimSpace(unit))]
+	if !ok || !known {
+		return
+	}
+	item["quantity"] = roundQuantity(quantity * conversion.factor)
+	item["unit"] = conversion.base
+}
+
+// roundQuantity rounds a converted quantity to three decimals to hide
+// floating point noise.
+func roundQuantity(quantity float64) float64 {
+	return math.Round(quantity*1000) / 1000
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestToMetricBase(t *testing.T) {
+	for _, tc := range []struct {
+		quantity     interface{}
+		unit         string
+		wantQuantity interface{}
+		wantUnit     string
+	}{
+		{1.5, "kg", 1500.0, "g"},
+		{250.0, "g", 250.0, "g"},
+		{2.0, "lb", 907.185, "g"},
+		{1.0, "cup", 236.588, "ml"},
+		{1.0, " Cup ", 236.588, "ml"},
+		{3.0, "tsp", 14.787, "ml"},
+		{0.5, "l", 500.0, "ml"},
+		{2.0, "fl oz", 59.147, "ml"},
+		// Unknown units and non-numeric quantities are left alone.
+		{4.0, "units", 4.0, "units"},
+		{"2", "kg", "2", "kg"},
+	} {
+		item := JSONData{"quantity": tc.quantity, "unit": tc.unit}
+		toMetricBase(item)
+		if item["quantity"] != tc.wantQuantity || item["unit"] != tc.wantUnit {
+			t.Errorf("%v %q = %v %q, want %v %q", tc.quantity, tc.unit, item["quantity"], item["unit"], tc.wantQuantity, tc.wantUnit)
+		}
+	}
+}
+
+func TestListItemsInMetricBaseUnits(t *testing.T) {
+	_, api := newTestAPI(t, "-strict-fields", "-strict-json")
+	mustDo(t, api, http.MethodPost, "/data/items", `{"id": "flour", "name": "Flour", "quantity": 1.5, "unit": "kg"}`, http.StatusCreated)
+
+	var items []JSONData
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data/items?unit=metric-base", "", http.StatusOK), &items)
+	if len(items) != 1 || items[0]["quantity"] != 1500.0 || items[0]["unit"] != "g" {
+		t.Fatalf("items = %v, want 1500 g of flour", items)
+	}
+	// The stored item keeps its unit.
+	decodeBody(t, mustDo(t, api, http.MethodGet, "/data/items", "", http.StatusOK), &items)
+	if items[0]["quantity"] != 1.5 || items[0]["unit"] != "kg" {
+		t.Fatalf("stored item = %v, want 1.5 kg", items[0])
+	}
+}
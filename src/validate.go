@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// hexColor matches CSS hex colors such as "#f00" or "#3b82f6".
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// fieldTypes are the type names accepted by -field-types.
+var knownFieldTypes = []string{"string", "number", "bool", "array", "object"}
+
+// jsonTypeOf names the -field-types type of a decoded JSON value.
+func jsonTypeOf(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// validateItem checks a single catalog item against the configured rules,
+// returning a *validationError that names the offending field.
+func validateItem(cfg *Config, item JSONData) error {
+	if cfg.StrictFields {
+		if err := checkItemFields(item); err != nil {
+			return err
+		}
+	}
+
+	fields := make([]string, 0, len(cfg.FieldTypes))
+	for field := range cfg.FieldTypes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		value, present := item[field]
+		if !present {
+			continue
+		}
+		if want, got := cfg.FieldTypes[field], jsonTypeOf(value); want != got {
+			return &validationError{msg: fmt.Sprintf("%s: expected %s, got %s", field, want, got)}
+		}
+	}
+
+	if expiresAt, present := item["expiresAt"]; present {
+		if _, ok := itemExpiry(item); !ok {
+			return &validationError{msg: fmt.Sprintf("expiresAt: %v is not an RFC 3339 timestamp", expiresAt)}
+		}
+	}
+
+	if id, ok := item["id"].(string); ok && id != "" && cfg.IDFormat != nil && !cfg.IDFormat.MatchString(id) {
+		return &validationError{msg: fmt.Sprintf("id: %s does not match the required format %s", id, cfg.IDFormat)}
+	}
+
+	if color, present := item["color"]; present {
+		str, ok := color.(string)
+		if !ok || !(hexColor.MatchString(str) || slices.Contains(cfg.ColorPalette, strings.ToLower(str))) {
+			return &validationError{msg: fmt.Sprintf("color: %v is not a hex code or one of %s", color, strings.Join(cfg.ColorPalette, ", "))}
+		}
+	}
+	return nil
+}
+
+// validateDocumentItem is validateItem plus the rules that only apply when
+// the whole document is written, such as -blank-names=reject. Single-item
+// endpoints require a name on their own.
+func validateDocumentItem(cfg *Config, item JSONData) error {
+	if cfg.BlankNames == "reject" && hasBlankName(item) {
+		return &validationError{msg: "name: must not be blank"}
+	}
+	return validateItem(cfg, item)
+}
+
+// validateDocument runs validateDocumentItem over every catalog item,
+// prefixing errors with the item's position so the client can find it.
+func validateDocument(cfg *Config, data JSONData) error {
+	for i, item := range catalogItems(data) {
+		if err := validateDocumentItem(cfg, item); err != nil {
+			return &validationError{msg: fmt.Sprintf("catalog[%d].%s", i, err.Error())}
+		}
+	}
+	return nil
+}
+
+// skippedItem reports a catalog item dropped by a lenient write.
+type skippedItem struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// dropInvalidItems removes the catalog items that fail
+// validateDocumentItem, along with their pending list entries, and reports
+// what was dropped. It lets lenient bulk writes apply the valid subset
+// instead of rejecting it all.
+func dropInvalidItems(cfg *Config, data JSONData) []skippedItem {
+	skipped := []skippedItem{}
+	var invalid []string
+	for i, item := range catalogItems(data) {
+		if err := validateDocumentItem(cfg, item); err != nil {
+			id, _ := item["id"].(string)
+			skipped = append(skipped, skippedItem{Index: i, ID: id, Reason: err.Error()})
+			invalid = append(invalid, id)
+		}
+	}
+	if len(skipped) > 0 {
+		kept := catalogItems(data)[:0]
+		for i, item := range catalogItems(data) {
+			if !slices.ContainsFunc(skipped, func(s skippedItem) bool { return s.Index == i }) {
+				kept = append(kept, item)
+			}
+		}
+		setCatalog(data, kept)
+		for _, id := range invalid {
+			if _, err := findItem(data, id); errors.Is(err, errItemNotFound) {
+				replacePendingRefs(data, id, nil)
+			}
+		}
+	}
+	return skipped
+}